@@ -0,0 +1,173 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// AdminPathPrefix is where AdminAPI mounts its endpoints:
+//
+//	GET    /__aduket/stubs     lists the served routes
+//	POST   /__aduket/stubs     adds a route from a StubDefinition
+//	PUT    /__aduket/stubs     replaces the response of a route
+//	DELETE /__aduket/stubs     removes the route given by ?method=&path=&host=
+//	GET    /__aduket/requests  lists the journal, optionally filtered by ?method=&path=
+//	DELETE /__aduket/requests  clears every recorder
+//	POST   /__aduket/reset     restores the initial routes and clears every recorder
+const AdminPathPrefix = "/__aduket"
+
+type adminRoute struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Host     string `json:"host,omitempty"`
+	Requests int    `json:"requests"`
+}
+
+type adminRequest struct {
+	Method      string      `json:"method"`
+	Path        string      `json:"path"`
+	QueryParams url.Values  `json:"queryParams,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        string      `json:"body,omitempty"`
+	StatusCode  int         `json:"statusCode"`
+	ReceivedAt  time.Time   `json:"receivedAt"`
+}
+
+type adminAPI struct {
+	server *Server
+}
+
+func registerAdminRoutes(e *echo.Echo, server *Server) {
+	admin := adminAPI{server: server}
+
+	e.GET(AdminPathPrefix+"/stubs", admin.listStubs)
+	e.POST(AdminPathPrefix+"/stubs", admin.addStub)
+	e.PUT(AdminPathPrefix+"/stubs", admin.replaceStub)
+	e.DELETE(AdminPathPrefix+"/stubs", admin.removeStub)
+	e.GET(AdminPathPrefix+"/requests", admin.listRequests)
+	e.DELETE(AdminPathPrefix+"/requests", admin.clearRequests)
+	e.POST(AdminPathPrefix+"/reset", admin.reset)
+}
+
+func (a adminAPI) listStubs(ctx echo.Context) error {
+	routes := a.server.Routes()
+	sortRoutes(routes)
+
+	stubs := make([]adminRoute, 0, len(routes))
+	for _, route := range routes {
+		recorder := a.server.Recorder(route)
+		if recorder == nil {
+			continue
+		}
+		recorder.mu.Lock()
+		requests := len(recorder.Requests)
+		recorder.mu.Unlock()
+
+		stubs = append(stubs, adminRoute{Method: route.HttpMethod, Path: route.Path, Host: route.Host, Requests: requests})
+	}
+	return ctx.JSON(http.StatusOK, stubs)
+}
+
+func (a adminAPI) addStub(ctx echo.Context) error {
+	route, options, err := readStubDefinition(ctx)
+	if err != nil {
+		return adminError(ctx, http.StatusBadRequest, err)
+	}
+	if _, err := a.server.AddRoute(route, options...); err != nil {
+		return adminError(ctx, http.StatusConflict, err)
+	}
+	return ctx.JSON(http.StatusCreated, adminRoute{Method: route.HttpMethod, Path: route.Path, Host: route.Host})
+}
+
+func (a adminAPI) replaceStub(ctx echo.Context) error {
+	route, options, err := readStubDefinition(ctx)
+	if err != nil {
+		return adminError(ctx, http.StatusBadRequest, err)
+	}
+	if err := a.server.ReplaceResponse(route, options...); err != nil {
+		return adminError(ctx, http.StatusNotFound, err)
+	}
+	return ctx.JSON(http.StatusOK, adminRoute{Method: route.HttpMethod, Path: route.Path, Host: route.Host})
+}
+
+func (a adminAPI) removeStub(ctx echo.Context) error {
+	route := Route{HttpMethod: strings.ToUpper(ctx.QueryParam("method")), Path: ctx.QueryParam("path"), Host: ctx.QueryParam("host")}
+	if err := a.server.RemoveRoute(route); err != nil {
+		return adminError(ctx, http.StatusNotFound, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (a adminAPI) listRequests(ctx echo.Context) error {
+	method, path := strings.ToUpper(ctx.QueryParam("method")), ctx.QueryParam("path")
+
+	requests := make([]adminRequest, 0)
+	for _, request := range a.server.Journal() {
+		if method != "" && request.Method != method || path != "" && request.Path != path {
+			continue
+		}
+		requests = append(requests, adminRequest{
+			Method:      request.Method,
+			Path:        request.Path,
+			QueryParams: request.QueryParams,
+			Header:      request.Header,
+			Body:        string(request.Body),
+			StatusCode:  request.StatusCode,
+			ReceivedAt:  request.ReceivedAt,
+		})
+	}
+	return ctx.JSON(http.StatusOK, requests)
+}
+
+func (a adminAPI) clearRequests(ctx echo.Context) error {
+	a.server.ClearRequests()
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (a adminAPI) reset(ctx echo.Context) error {
+	a.server.Reset()
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func readStubDefinition(ctx echo.Context) (Route, []ResponseRuleOption, error) {
+	body, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return Route{}, nil, err
+	}
+
+	var definition StubDefinition
+	if err := json.Unmarshal(body, &definition); err != nil {
+		return Route{}, nil, err
+	}
+	options, err := definition.ResponseRuleOptions()
+	if err != nil {
+		return Route{}, nil, err
+	}
+	return definition.Route(), options, nil
+}
+
+func adminError(ctx echo.Context, statusCode int, err error) error {
+	return ctx.JSON(statusCode, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,170 @@
+package aduket
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func adminDo(t *testing.T, method, url, body string) (int, string) {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.Nil(t, err)
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	assert.Nil(t, err)
+	return response.StatusCode, string(responseBody)
+}
+
+func TestAdminAPIStubs(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{}, AdminAPI())
+	defer server.Close()
+	stubsURL := server.URL() + AdminPathPrefix + "/stubs"
+
+	statusCode, _ := adminDo(t, http.MethodPost, stubsURL, `{
+		"method": "get",
+		"path": "/user/:id",
+		"statusCode": 200,
+		"header": {"X-Mock": ["aduket"]},
+		"jsonBody": {"id": 1, "name": "kalt"}
+	}`)
+	assert.Equal(t, http.StatusCreated, statusCode)
+
+	response, err := http.Get(server.URL() + "/user/1")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, "aduket", response.Header.Get("X-Mock"))
+	assert.JSONEq(t, `{"id": 1, "name": "kalt"}`, string(body))
+
+	statusCode, _ = adminDo(t, http.MethodPost, stubsURL, `{"method": "GET", "path": "/user/:id"}`)
+	assert.Equal(t, http.StatusConflict, statusCode)
+
+	statusCode, _ = adminDo(t, http.MethodPut, stubsURL, `{"method": "GET", "path": "/user/:id", "statusCode": 404}`)
+	assert.Equal(t, http.StatusOK, statusCode)
+	response, err = http.Get(server.URL() + "/user/1")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	statusCode, stubs := adminDo(t, http.MethodGet, stubsURL, "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.JSONEq(t, `[{"method": "GET", "path": "/user/:id", "requests": 2}]`, stubs)
+
+	statusCode, _ = adminDo(t, http.MethodDelete, stubsURL+"?method=GET&path=/user/:id", "")
+	assert.Equal(t, http.StatusNoContent, statusCode)
+	statusCode, _ = adminDo(t, http.MethodDelete, stubsURL+"?method=GET&path=/user/:id", "")
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	assert.Empty(t, server.Routes())
+}
+
+func TestAdminAPIInvalidStub(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{}, AdminAPI())
+	defer server.Close()
+	stubsURL := server.URL() + AdminPathPrefix + "/stubs"
+
+	for _, definition := range []string{
+		`{"method": "GET"`,
+		`{"path": "/user"}`,
+		`{"method": "GET", "path": "user"}`,
+		`{"method": "GET", "path": "/user", "timeout": "soon"}`,
+		`{"method": "GET", "path": "/user", "jsonBody": {}, "stringBody": "kalt"}`,
+	} {
+		statusCode, body := adminDo(t, http.MethodPost, stubsURL, definition)
+		assert.Equal(t, http.StatusBadRequest, statusCode, definition)
+		assert.Contains(t, body, `"error"`)
+	}
+}
+
+func TestAdminAPIRequests(t *testing.T) {
+	route := Route{HttpMethod: http.MethodPost, Path: "/user"}
+	server := NewManagedServer(map[Route][]ResponseRuleOption{route: {StatusCode(http.StatusCreated)}}, AdminAPI())
+	defer server.Close()
+	requestsURL := server.URL() + AdminPathPrefix + "/requests"
+
+	_, err := http.Post(server.URL()+"/user?notify=true", "application/json", strings.NewReader(`{"name":"kalt"}`))
+	assert.Nil(t, err)
+	_, err = http.Get(server.URL() + "/missing")
+	assert.Nil(t, err)
+
+	statusCode, body := adminDo(t, http.MethodGet, requestsURL+"?method=post", "")
+	assert.Equal(t, http.StatusOK, statusCode)
+
+	var requests []adminRequest
+	assert.Nil(t, json.Unmarshal([]byte(body), &requests))
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "/user", requests[0].Path)
+	assert.Equal(t, `{"name":"kalt"}`, requests[0].Body)
+	assert.Equal(t, "true", requests[0].QueryParams.Get("notify"))
+	assert.Equal(t, http.StatusCreated, requests[0].StatusCode)
+
+	_, body = adminDo(t, http.MethodGet, requestsURL, "")
+	assert.Nil(t, json.Unmarshal([]byte(body), &requests))
+	assert.Len(t, requests, 2)
+
+	statusCode, _ = adminDo(t, http.MethodDelete, requestsURL, "")
+	assert.Equal(t, http.StatusNoContent, statusCode)
+	assert.Empty(t, server.Journal())
+	assert.Equal(t, []Route{route}, server.Routes())
+}
+
+func TestAdminAPIReset(t *testing.T) {
+	route := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	server := NewManagedServer(map[Route][]ResponseRuleOption{route: {}}, AdminAPI())
+	defer server.Close()
+
+	statusCode, _ := adminDo(t, http.MethodPost, server.URL()+AdminPathPrefix+"/stubs", `{"method": "GET", "path": "/book"}`)
+	assert.Equal(t, http.StatusCreated, statusCode)
+	_, err := http.Get(server.URL() + "/book")
+	assert.Nil(t, err)
+
+	statusCode, _ = adminDo(t, http.MethodPost, server.URL()+AdminPathPrefix+"/reset", "")
+	assert.Equal(t, http.StatusNoContent, statusCode)
+	assert.Equal(t, []Route{route}, server.Routes())
+	assert.Empty(t, server.Journal())
+}
+
+func TestAdminAPIDisabledByDefault(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{})
+	defer server.Close()
+
+	response, err := http.Get(server.URL() + AdminPathPrefix + "/stubs")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestAdminAPIHostStubs(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{}, AdminAPI())
+	defer server.Close()
+	stubsURL := server.URL() + AdminPathPrefix + "/stubs"
+
+	statusCode, body := adminDo(t, http.MethodPost, stubsURL, `{"method": "GET", "path": "/user", "host": "users.internal", "stringBody": "kalt"}`)
+	assert.Equal(t, http.StatusCreated, statusCode)
+	assert.JSONEq(t, `{"method": "GET", "path": "/user", "host": "users.internal", "requests": 0}`, body)
+
+	response, err := server.HostClient("users.internal").Get("http://users.internal/user")
+	assert.Nil(t, err)
+	responseBody, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, "kalt", string(responseBody))
+
+	statusCode, _ = adminDo(t, http.MethodDelete, stubsURL+"?method=GET&path=/user", "")
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	statusCode, _ = adminDo(t, http.MethodDelete, stubsURL+"?method=GET&path=/user&host=users.internal", "")
+	assert.Equal(t, http.StatusNoContent, statusCode)
+	assert.Empty(t, server.Routes())
+}
+
+func TestAdminAPIRequiresManagedServer(t *testing.T) {
+	assert.PanicsWithError(t, "aduket: AdminAPI is only supported by NewManagedServer and New", func() {
+		NewMultiRouteServer(map[Route][]ResponseRuleOption{}, AdminAPI())
+	})
+	assert.Panics(t, func() {
+		NewServerWithOptions(http.MethodGet, "/user", nil, AdminAPI())
+	})
+}
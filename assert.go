@@ -17,27 +17,52 @@
 package aduket
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func (r RequestRecorder) AssertStringBodyEqual(t *testing.T, expectedBody string) bool {
+func (r *RequestRecorder) AssertStringBodyEqual(t *testing.T, expectedBody string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	return assert.Equal(t, expectedBody, string(r.Data))
 }
 
-func (r RequestRecorder) AssertJSONBodyEqual(t *testing.T, expectedBody interface{}) bool {
+// AssertJSONBodyEqual compares the body and expectedBody as JSON values, so
+// key order, whitespace and number formatting don't matter. A failure lists
+// every difference by JSON path.
+func (r *RequestRecorder) AssertJSONBodyEqual(t *testing.T, expectedBody interface{}, options ...JSONCompareOption) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	expectedBodyBytes, err := json.Marshal(expectedBody)
 	if err != nil {
 		t.Error("expected body could not marshaled to json")
+		return false
 	}
-	return assert.Equal(t, string(expectedBodyBytes), string(r.Body))
+
+	differences, err := jsonDiff(expectedBodyBytes, r.Body, options...)
+	if err != nil {
+		return assert.Fail(t, err.Error())
+	}
+	if len(differences) != 0 {
+		return assert.Fail(t, "JSON bodies differ:\n"+strings.Join(differences, "\n"))
+	}
+	return true
 }
 
-func (r RequestRecorder) AssertXMLBodyEqual(t *testing.T, expectedXMLBody interface{}) bool {
+func (r *RequestRecorder) AssertXMLBodyEqual(t *testing.T, expectedXMLBody interface{}) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	expectedBodyBytes, err := xml.Marshal(expectedXMLBody)
 	if err != nil {
 		t.Error("expected body could not marshaled to xml")
@@ -45,26 +70,219 @@ func (r RequestRecorder) AssertXMLBodyEqual(t *testing.T, expectedXMLBody interf
 	return assert.Equal(t, string(expectedBodyBytes), string(r.Body))
 }
 
-func (r RequestRecorder) AssertParamEqual(t *testing.T, paramName, paramValue string) bool {
+func (r *RequestRecorder) AssertParamEqual(t *testing.T, paramName, paramValue string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	return assert.Equal(t, paramValue, r.Params[paramName])
 }
 
-func (r RequestRecorder) AssertQueryParamEqual(t *testing.T, queryParamName string, queryParamValues []string) bool {
+func (r *RequestRecorder) AssertQueryParamEqual(t *testing.T, queryParamName string, queryParamValues []string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	return assert.Equal(t, queryParamValues, r.QueryParams[queryParamName])
 }
 
-func (r RequestRecorder) AssertFormParamEqual(t *testing.T, formParamName string, formValues []string) bool {
+func (r *RequestRecorder) AssertFormParamEqual(t *testing.T, formParamName string, formValues []string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	return assert.Equal(t, formValues, r.FormParams[formParamName])
 }
 
-func (r RequestRecorder) AssertHeaderContains(t *testing.T, expectedHeader http.Header) bool {
+func (r *RequestRecorder) AssertHeaderContains(t *testing.T, expectedHeader http.Header) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	return assert.True(t, isHeaderContains(expectedHeader, r.Header))
 }
 
-func (r RequestRecorder) AssertNoRequest(t *testing.T) bool {
+func (r *RequestRecorder) AssertNoRequest(t *testing.T) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	return assert.False(t, r.isRequestReceived)
 }
 
+// AssertValidAgainstSpec checks that every recorded request conforms to the
+// OpenAPI operation it matched.
+func (r *RequestRecorder) AssertValidAgainstSpec(t *testing.T) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var violations []string
+	for _, request := range r.Requests {
+		for _, violation := range request.SpecViolations {
+			violations = append(violations, fmt.Sprintf("%s %s: %s", request.Method, request.Path, violation))
+		}
+	}
+	return assert.Empty(t, violations, "requests violate the OpenAPI spec")
+}
+
+func (r *RequestRecorder) AssertPreflightEqual(t *testing.T, expectedPreflight PreflightRequest) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !assert.NotEmpty(t, r.Preflights, "no preflight request received") {
+		return false
+	}
+	return assert.Equal(t, expectedPreflight, *r.Preflights[len(r.Preflights)-1])
+}
+
+func (r *RequestRecorder) AssertNoPreflight(t *testing.T) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return assert.Empty(t, r.Preflights)
+}
+
+// AssertAllPagesWalkedOnce checks that a Paginate route served every page of
+// its dataset exactly once.
+func (r *RequestRecorder) AssertAllPagesWalkedOnce(t *testing.T) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !assert.NotEmpty(t, r.PagesServed, "no page served") {
+		return false
+	}
+
+	expectedPages := make([]int, r.pageCount)
+	for i := range expectedPages {
+		expectedPages[i] = i + 1
+	}
+	return assert.ElementsMatch(t, expectedPages, r.PagesServed)
+}
+
+func (r *RequestRecorder) AssertHeaderKeptOnRedirect(t *testing.T, headerName string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !assert.NotEmpty(t, r.RedirectHops, "no redirect followed") {
+		return false
+	}
+	hop := r.RedirectHops[len(r.RedirectHops)-1]
+	return assert.Contains(t, hop.KeptHeaders, http.CanonicalHeaderKey(headerName))
+}
+
+func (r *RequestRecorder) AssertHeaderDroppedOnRedirect(t *testing.T, headerName string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !assert.NotEmpty(t, r.RedirectHops, "no redirect followed") {
+		return false
+	}
+	hop := r.RedirectHops[len(r.RedirectHops)-1]
+	return assert.Contains(t, hop.DroppedHeaders, http.CanonicalHeaderKey(headerName))
+}
+
+func (r *RequestRecorder) AssertCookieSent(t *testing.T, name, value string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cookie, err := (&http.Request{Header: r.Header}).Cookie(name)
+	if !assert.NoError(t, err, "cookie %s not sent", name) {
+		return false
+	}
+	return assert.Equal(t, value, cookie.Value)
+}
+
+// AssertSameSession checks that two recorded requests, possibly of different
+// routes, carried the same session.
+func AssertSameSession(t *testing.T, requestA, requestB *RecordedRequest) bool {
+	if !assert.NotEmpty(t, requestA.SessionID, "request has no session") {
+		return false
+	}
+	return assert.Equal(t, requestA.SessionID, requestB.SessionID)
+}
+
+func (r *RequestRecorder) AssertClientCertificate(t *testing.T, commonName string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !assert.NotNil(t, r.TLS, "request not received over TLS") {
+		return false
+	}
+	if !assert.NotEmpty(t, r.TLS.PeerCertificates, "no client certificate presented") {
+		return false
+	}
+	return assert.Equal(t, commonName, r.TLS.PeerCertificates[0].Subject.CommonName)
+}
+
+func (r *RequestRecorder) AssertTLSVersion(t *testing.T, version uint16) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !assert.NotNil(t, r.TLS, "request not received over TLS") {
+		return false
+	}
+	return assert.Equal(t, tls.VersionName(version), tls.VersionName(r.TLS.Version))
+}
+
+func (r *RequestRecorder) AssertProtocol(t *testing.T, protocol string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return assert.Equal(t, protocol, r.Protocol)
+}
+
+func (r *RequestRecorder) AssertJWTClaim(t *testing.T, claimName string, expectedValue interface{}) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.assertBearerJWT(t)
+	if !ok {
+		return false
+	}
+	return assert.EqualValues(t, expectedValue, token.claims[claimName])
+}
+
+func (r *RequestRecorder) AssertJWTNotExpired(t *testing.T) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.assertBearerJWT(t)
+	if !ok {
+		return false
+	}
+	return assert.NoError(t, token.checkExpiry(time.Now()))
+}
+
+// AssertJWTSignedBy verifies the bearer token signature with an *rsa.PublicKey
+// (RS256) or a []byte shared secret (HS256).
+func (r *RequestRecorder) AssertJWTSignedBy(t *testing.T, key interface{}) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.assertBearerJWT(t)
+	if !ok {
+		return false
+	}
+	return assert.NoError(t, token.verify(key))
+}
+
+func (r *RequestRecorder) AssertJWTSignedByKeySet(t *testing.T, keySet JSONWebKeySet) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.assertBearerJWT(t)
+	if !ok {
+		return false
+	}
+	return assert.NoError(t, token.verifyWithKeySet(keySet))
+}
+
+// assertBearerJWT must be called with r.mu held.
+func (r *RequestRecorder) assertBearerJWT(t *testing.T) (jwt, bool) {
+	rawToken, ok := bearerToken(&http.Request{Header: r.Header})
+	if !assert.True(t, ok, "request has no bearer token") {
+		return jwt{}, false
+	}
+
+	token, err := parseJWT(rawToken)
+	return token, assert.NoError(t, err)
+}
+
 func isHeaderContains(expectedHeader, actualHeader http.Header) bool {
 	assertionResult := true
 	for key, value := range expectedHeader {
@@ -76,7 +294,11 @@ func isHeaderContains(expectedHeader, actualHeader http.Header) bool {
 
 func isJSONEqual(expectedBody interface{}, actualBody Body) (bool, error) {
 	expectedBytes, err := json.Marshal(expectedBody)
-	return assert.ObjectsAreEqualValues(expectedBytes, actualBody), err
+	if err != nil {
+		return false, err
+	}
+	differences, err := jsonDiff(expectedBytes, actualBody)
+	return err == nil && len(differences) == 0, err
 }
 
 func isXMLEqual(expectedBody interface{}, actualBody Body) (bool, error) {
@@ -2,6 +2,11 @@ package aduket
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
 	"net/http"
@@ -9,6 +14,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo"
 	"github.com/stretchr/testify/assert"
@@ -60,6 +66,39 @@ func TestAssertJSONBodyEqual(t *testing.T) {
 	assert.True(t, tester.Failed())
 }
 
+func TestAssertJSONBodyEqual_Semantic(t *testing.T) {
+	request := newStringRequest(http.MethodPost, "", `{
+		"tags": ["b", "a"],
+		"price": 10.50,
+		"id": 1e2,
+		"meta": {"requestId": "f81d", "createdAt": "2020-01-01"}
+	}`)
+
+	ctx := echo.New().NewContext(request, nil)
+
+	requestRecorder := NewRequestRecorder()
+	requestRecorder.saveContext(ctx)
+
+	tester := &testing.T{}
+
+	expected := map[string]interface{}{
+		"id":    100,
+		"price": 10.5,
+		"tags":  []string{"b", "a"},
+		"meta":  map[string]string{"createdAt": "2020-01-01", "requestId": "f81d"},
+	}
+	assert.True(t, requestRecorder.AssertJSONBodyEqual(tester, expected))
+	assert.False(t, tester.Failed())
+
+	expected["tags"] = []string{"a", "b"}
+	expected["meta"] = map[string]string{"requestId": "other"}
+	assert.True(t, requestRecorder.AssertJSONBodyEqual(tester, expected, UnorderedArrays(), IgnoreFields("meta.requestId", "$.meta.createdAt")))
+	assert.False(t, tester.Failed())
+
+	assert.False(t, requestRecorder.AssertJSONBodyEqual(tester, expected))
+	assert.True(t, tester.Failed())
+}
+
 func TestAssertStringBodyEqual(t *testing.T) {
 	expectedPayload := "Hello"
 	request := newStringRequest(http.MethodPost, "", expectedPayload)
@@ -182,6 +221,68 @@ func TestAssertNoRequest(t *testing.T) {
 	assert.True(t, tester.Failed())
 }
 
+func TestAssertJWT(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token, _ := signJWT(key, "k1", map[string]interface{}{
+		"sub": "42",
+		"exp": time.Now().Add(time.Minute).Unix(),
+		"age": 7,
+	})
+
+	request := newStringRequest(http.MethodGet, "", "")
+	request.Header.Set("Authorization", "Bearer "+token)
+	ctx := echo.New().NewContext(request, nil)
+
+	requestRecorder := NewRequestRecorder()
+	requestRecorder.saveContext(ctx)
+
+	tester := &testing.T{}
+
+	assert.True(t, requestRecorder.AssertJWTClaim(tester, "sub", "42"))
+	assert.True(t, requestRecorder.AssertJWTClaim(tester, "age", 7))
+	assert.True(t, requestRecorder.AssertJWTNotExpired(tester))
+	assert.True(t, requestRecorder.AssertJWTSignedBy(tester, &key.PublicKey))
+	assert.True(t, requestRecorder.AssertJWTSignedByKeySet(tester, JSONWebKeySet{Keys: []JSONWebKey{newJSONWebKey("k1", &key.PublicKey)}}))
+	assert.False(t, tester.Failed())
+
+	assert.False(t, requestRecorder.AssertJWTClaim(tester, "sub", "24"))
+	assert.True(t, tester.Failed())
+
+	tester = &testing.T{}
+	assert.False(t, requestRecorder.AssertJWTSignedBy(tester, &otherKey.PublicKey))
+	assert.False(t, requestRecorder.AssertJWTSignedByKeySet(tester, JSONWebKeySet{Keys: []JSONWebKey{newJSONWebKey("k1", &otherKey.PublicKey)}}))
+	assert.True(t, tester.Failed())
+}
+
+func TestAssertJWTExpiredAndHS256(t *testing.T) {
+	secret := []byte("hadouken")
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"42","exp":1}`))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(header + "." + claims))
+	token := header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	request := newStringRequest(http.MethodGet, "", "")
+	request.Header.Set("Authorization", "Bearer "+token)
+	ctx := echo.New().NewContext(request, nil)
+
+	requestRecorder := NewRequestRecorder()
+	requestRecorder.saveContext(ctx)
+
+	tester := &testing.T{}
+
+	assert.True(t, requestRecorder.AssertJWTSignedBy(tester, secret))
+	assert.False(t, tester.Failed())
+
+	assert.False(t, requestRecorder.AssertJWTNotExpired(tester))
+	assert.True(t, tester.Failed())
+
+	tester = &testing.T{}
+	assert.False(t, NewRequestRecorder().AssertJWTClaim(tester, "sub", "42"))
+	assert.True(t, tester.Failed())
+}
+
 func newStringRequest(method, url, body string) *http.Request {
 	request, _ := http.NewRequest(method, url, strings.NewReader(body))
 	return request
@@ -0,0 +1,216 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const authRealm = "aduket"
+
+type authenticator interface {
+	authenticate(request *http.Request) bool
+	challenge(request *http.Request) string
+}
+
+type basicAuthenticator struct {
+	username string
+	password string
+}
+
+func (b basicAuthenticator) authenticate(request *http.Request) bool {
+	username, password, ok := request.BasicAuth()
+	return ok && secureEqual(username, b.username) && secureEqual(password, b.password)
+}
+
+func (b basicAuthenticator) challenge(request *http.Request) string {
+	return fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, authRealm)
+}
+
+type bearerAuthenticator struct {
+	tokens []string
+}
+
+func (b bearerAuthenticator) authenticate(request *http.Request) bool {
+	token, ok := bearerToken(request)
+	return ok && containsSecure(b.tokens, token)
+}
+
+func (b bearerAuthenticator) challenge(request *http.Request) string {
+	if _, ok := bearerToken(request); ok {
+		return fmt.Sprintf(`Bearer realm="%s", error="invalid_token"`, authRealm)
+	}
+	return fmt.Sprintf(`Bearer realm="%s"`, authRealm)
+}
+
+type apiKeyAuthenticator struct {
+	header string
+	keys   []string
+}
+
+func (a apiKeyAuthenticator) authenticate(request *http.Request) bool {
+	key := request.Header.Get(a.header)
+	return key != "" && containsSecure(a.keys, key)
+}
+
+func (a apiKeyAuthenticator) challenge(request *http.Request) string {
+	return fmt.Sprintf(`ApiKey realm="%s", header="%s"`, authRealm, a.header)
+}
+
+// maxDigestNonces bounds the nonces a digest authenticator remembers; the
+// oldest are forgotten first.
+const maxDigestNonces = 1024
+
+type digestAuthenticator struct {
+	realm    string
+	username string
+	password string
+
+	mu         sync.Mutex
+	nonces     map[string]bool
+	nonceOrder []string
+}
+
+func newDigestAuthenticator(realm, username, password string) *digestAuthenticator {
+	return &digestAuthenticator{
+		realm:    realm,
+		username: username,
+		password: password,
+		nonces:   make(map[string]bool),
+	}
+}
+
+func (d *digestAuthenticator) authenticate(request *http.Request) bool {
+	authorization := request.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Digest ") {
+		return false
+	}
+
+	params := parseAuthParams(strings.TrimPrefix(authorization, "Digest "))
+	if params["username"] != d.username || params["realm"] != d.realm || !d.isIssued(params["nonce"]) {
+		return false
+	}
+	// The digest only covers the uri parameter, which must name the
+	// requested resource.
+	if params["uri"] != request.URL.RequestURI() {
+		return false
+	}
+
+	ha1 := md5Hex(d.username + ":" + d.realm + ":" + d.password)
+	ha2 := md5Hex(request.Method + ":" + params["uri"])
+
+	var expected string
+	if params["qop"] == "" {
+		expected = md5Hex(ha1 + ":" + params["nonce"] + ":" + ha2)
+	} else {
+		expected = md5Hex(strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+	}
+
+	return secureEqual(expected, params["response"])
+}
+
+func (d *digestAuthenticator) challenge(request *http.Request) string {
+	nonce := randomHex(16)
+
+	d.mu.Lock()
+	d.nonces[nonce] = true
+	d.nonceOrder = append(d.nonceOrder, nonce)
+	if len(d.nonceOrder) > maxDigestNonces {
+		delete(d.nonces, d.nonceOrder[0])
+		d.nonceOrder = d.nonceOrder[1:]
+	}
+	d.mu.Unlock()
+
+	return fmt.Sprintf(`Digest realm="%s", qop="auth", algorithm=MD5, nonce="%s", opaque="%s"`, d.realm, nonce, md5Hex(d.realm))
+}
+
+func (d *digestAuthenticator) isIssued(nonce string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.nonces[nonce]
+}
+
+func bearerToken(request *http.Request) (string, bool) {
+	authorization := request.Header.Get("Authorization")
+	if len(authorization) < len("Bearer ") || !strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(authorization[len("Bearer "):]), true
+}
+
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				value, s = s, ""
+			} else {
+				value, s = s[:end], s[end:]
+			}
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return params
+}
+
+func containsSecure(candidates []string, value string) bool {
+	for _, candidate := range candidates {
+		if secureEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,168 @@
+package aduket
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAuth(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodGet, "/user", RequireBasicAuth("ken", "hadouken"), StringBody("Hello"))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/user", http.NoBody)
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	assert.Equal(t, `Basic realm="aduket", charset="UTF-8"`, response.Header.Get("WWW-Authenticate"))
+
+	request.SetBasicAuth("ken", "shoryuken")
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	request.SetBasicAuth("ken", "hadouken")
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	assert.Len(t, requestRecorder.Requests, 3)
+	assert.Equal(t, http.StatusUnauthorized, requestRecorder.Requests[0].StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, requestRecorder.Requests[1].StatusCode)
+	assert.Equal(t, http.StatusOK, requestRecorder.Requests[2].StatusCode)
+}
+
+func TestBearerAuth(t *testing.T) {
+	server, _ := NewServer(http.MethodGet, "/user", RequireBearer("old", "fresh"))
+	defer server.Close()
+
+	tests := []struct {
+		authorization     string
+		expectedStatus    int
+		expectedChallenge string
+	}{
+		{"", http.StatusUnauthorized, `Bearer realm="aduket"`},
+		{"Bearer expired", http.StatusUnauthorized, `Bearer realm="aduket", error="invalid_token"`},
+		{"Bearer fresh", http.StatusOK, ""},
+		{"bearer old", http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/user", http.NoBody)
+		if test.authorization != "" {
+			request.Header.Set("Authorization", test.authorization)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expectedStatus, response.StatusCode)
+		assert.Equal(t, test.expectedChallenge, response.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	server, _ := NewServer(http.MethodGet, "/user", RequireAPIKey("X-Api-Key", "k3y"))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/user", http.NoBody)
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	assert.Equal(t, `ApiKey realm="aduket", header="X-Api-Key"`, response.Header.Get("WWW-Authenticate"))
+
+	request.Header.Set("X-Api-Key", "k3y")
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestDigestAuth(t *testing.T) {
+	server, _ := NewServer(http.MethodGet, "/user", RequireDigestAuth("streetbyters", "ken", "hadouken"))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/user", http.NoBody)
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	challenge := response.Header.Get("WWW-Authenticate")
+	assert.True(t, strings.HasPrefix(challenge, "Digest "))
+	params := parseAuthParams(strings.TrimPrefix(challenge, "Digest "))
+	assert.Equal(t, "streetbyters", params["realm"])
+
+	ha1 := md5Hex("ken:streetbyters:hadouken")
+	ha2 := md5Hex("GET:/user")
+	digest := md5Hex(ha1 + ":" + params["nonce"] + ":00000001:c0ffee:auth:" + ha2)
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		`Digest username="ken", realm="streetbyters", nonce="%s", uri="/user", qop=auth, nc=00000001, cnonce="c0ffee", response="%s", opaque="%s"`,
+		params["nonce"], digest, params["opaque"],
+	))
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		`Digest username="ken", realm="streetbyters", nonce="unknown", uri="/user", qop=auth, nc=00000001, cnonce="c0ffee", response="%s"`,
+		digest,
+	))
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
+
+func TestDigestAuthRejectsURIMismatch(t *testing.T) {
+	server, _ := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/user"}: {RequireDigestAuth("streetbyters", "ken", "hadouken")},
+	})
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/user")
+	assert.Nil(t, err)
+	params := parseAuthParams(strings.TrimPrefix(response.Header.Get("WWW-Authenticate"), "Digest "))
+
+	ha1 := md5Hex("ken:streetbyters:hadouken")
+	digest := md5Hex(ha1 + ":" + params["nonce"] + ":00000001:c0ffee:auth:" + md5Hex("GET:/public"))
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/user", http.NoBody)
+	request.Header.Set("Authorization", fmt.Sprintf(
+		`Digest username="ken", realm="streetbyters", nonce="%s", uri="/public", qop=auth, nc=00000001, cnonce="c0ffee", response="%s"`,
+		params["nonce"], digest,
+	))
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
+
+func TestDigestAuthBoundsNonces(t *testing.T) {
+	authenticator := newDigestAuthenticator("streetbyters", "ken", "hadouken")
+	request, _ := http.NewRequest(http.MethodGet, "/user", http.NoBody)
+
+	first := parseAuthParams(strings.TrimPrefix(authenticator.challenge(request), "Digest "))["nonce"]
+	for i := 0; i < maxDigestNonces; i++ {
+		authenticator.challenge(request)
+	}
+
+	assert.Len(t, authenticator.nonces, maxDigestNonces)
+	assert.False(t, authenticator.isIssued(first))
+}
+
+func TestAuthIsCheckedBeforeFaults(t *testing.T) {
+	server, requestRecorder := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/slow"}:    {RequireBearer("s3cr3t"), Timeout(time.Minute)},
+		{HttpMethod: http.MethodGet, Path: "/corrupt"}: {RequireBearer("s3cr3t"), CorruptedBody()},
+	})
+	defer server.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	for _, path := range []string{"/slow", "/corrupt"} {
+		response, err := client.Get(server.URL + path)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, requestRecorder[Route{HttpMethod: http.MethodGet, Path: path}].Requests[0].StatusCode)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command aduket serves mocks described in a YAML, JSON or TOML config file:
+//
+//	aduket serve --config mocks.yaml [--address 127.0.0.1:8080] [--admin] [--reload-interval 1s]
+//
+// The config is reloaded whenever the file changes; see aduket.Config for its
+// format.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/streetbyters/aduket"
+)
+
+const defaultAddress = "127.0.0.1:8080"
+
+func main() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		<-stop
+		close(done)
+	}()
+
+	if err := run(os.Args[1:], os.Stdout, done); err != nil {
+		fmt.Fprintln(os.Stderr, "aduket:", err)
+		os.Exit(2)
+	}
+}
+
+func run(args []string, out io.Writer, done <-chan struct{}) error {
+	if len(args) == 0 || args[0] != "serve" {
+		return errors.New("usage: aduket serve --config <file> [--address host:port] [--admin] [--reload-interval 1s]")
+	}
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configPath := flags.String("config", "", "YAML, JSON or TOML file describing the routes")
+	address := flags.String("address", "", "address to listen on, overriding the config (default "+defaultAddress+")")
+	adminAPI := flags.Bool("admin", false, "serve the admin API under "+aduket.AdminPathPrefix)
+	reloadInterval := flags.Duration("reload-interval", time.Second, "how often to check the config for changes, 0 disables reloading")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		return errors.New("serve: --config is required")
+	}
+
+	watcher := &configWatcher{path: *configPath, out: out}
+	data, err := ioutil.ReadFile(*configPath)
+	if err != nil {
+		return err
+	}
+	config, routeResponseOptions, err := watcher.load(data)
+	if err != nil {
+		return err
+	}
+
+	listenAddress := *address
+	if listenAddress == "" {
+		listenAddress = config.Address
+	}
+	if listenAddress == "" {
+		listenAddress = defaultAddress
+	}
+
+	serverOptions := []aduket.ServerOption{aduket.ListenAddress(listenAddress)}
+	if *adminAPI {
+		serverOptions = append(serverOptions, aduket.AdminAPI())
+	}
+	server, err := aduket.StartManagedServer(routeResponseOptions, serverOptions...)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	watcher.server = server
+
+	fmt.Fprintf(out, "aduket: serving %d routes from %s on %s\n", len(config.Routes), *configPath, server.URL())
+
+	if *reloadInterval <= 0 {
+		<-done
+		return nil
+	}
+
+	ticker := time.NewTicker(*reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+			watcher.reloadIfChanged()
+		}
+	}
+}
+
+type configWatcher struct {
+	path   string
+	out    io.Writer
+	server *aduket.Server
+	loaded []byte
+}
+
+// load parses data, the content of the config file, and remembers it so
+// reloadIfChanged skips unchanged files.
+func (w *configWatcher) load(data []byte) (*aduket.Config, map[aduket.Route][]aduket.ResponseRuleOption, error) {
+	w.loaded = data
+	return aduket.ParseConfig(w.path, data)
+}
+
+// reloadIfChanged swaps in the routes of the config when the file content
+// changed. A broken config is reported and the running routes are kept.
+func (w *configWatcher) reloadIfChanged() {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil || bytes.Equal(data, w.loaded) {
+		return
+	}
+
+	config, routeResponseOptions, err := w.load(data)
+	if err != nil {
+		fmt.Fprintf(w.out, "aduket: reload failed, keeping previous routes: %v\n", err)
+		return
+	}
+
+	w.server.SetRoutes(routeResponseOptions)
+	fmt.Fprintf(w.out, "aduket: reloaded %d routes from %s\n", len(config.Routes), w.path)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
+
+const mocksYAML = `
+routes:
+  - method: GET
+    path: /user/:id
+    statusCode: 200
+    header:
+      Content-Type: [application/json]
+    jsonBody:
+      id: 1
+      name: kalt
+  - method: POST
+    path: /user
+    statusCode: 201
+    timeout: 10ms
+`
+
+func TestServeWithHotReload(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "mocks.yaml")
+	assert.Nil(t, ioutil.WriteFile(configPath, []byte(mocksYAML), 0644))
+
+	out := &syncBuffer{}
+	done := make(chan struct{})
+	finished := make(chan error)
+	go func() {
+		finished <- run([]string{"serve", "--config", configPath, "--address", "127.0.0.1:0", "--reload-interval", "10ms"}, out, done)
+	}()
+
+	var url string
+	assert.Eventually(t, func() bool {
+		url = regexp.MustCompile(`http://\S+`).FindString(out.String())
+		return url != ""
+	}, time.Second, 5*time.Millisecond)
+
+	response, err := http.Get(url + "/user/1")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"id": 1, "name": "kalt"}`, string(body))
+
+	assert.Nil(t, ioutil.WriteFile(configPath, []byte("routes: [{method: GET, path: /user/:id, statusCode: 404}]"), 0644))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "reloaded 1 routes")
+	}, time.Second, 5*time.Millisecond)
+
+	response, err = http.Get(url + "/user/1")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	response, err = http.Post(url+"/user", "application/json", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	assert.Nil(t, ioutil.WriteFile(configPath, []byte("routes: [{method: GET, path: /user, timeout: soon}]"), 0644))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "reload failed")
+	}, time.Second, 5*time.Millisecond)
+
+	response, err = http.Get(url + "/user/1")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	close(done)
+	assert.Nil(t, <-finished)
+}
+
+func TestServeJSONConfigWithAdminAPI(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "mocks.json")
+	assert.Nil(t, ioutil.WriteFile(configPath, []byte(`{"routes": [{"method": "GET", "path": "/ping", "stringBody": "pong"}]}`), 0644))
+
+	out := &syncBuffer{}
+	done := make(chan struct{})
+	finished := make(chan error)
+	go func() {
+		finished <- run([]string{"serve", "--config", configPath, "--address", "127.0.0.1:0", "--admin", "--reload-interval", "0"}, out, done)
+	}()
+
+	var url string
+	assert.Eventually(t, func() bool {
+		url = regexp.MustCompile(`http://\S+`).FindString(out.String())
+		return url != ""
+	}, time.Second, 5*time.Millisecond)
+
+	response, err := http.Get(url + "/ping")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, "pong", string(body))
+
+	response, err = http.Get(url + "/__aduket/requests")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	close(done)
+	assert.Nil(t, <-finished)
+}
+
+func TestServeErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalidPath := filepath.Join(dir, "mocks.yaml")
+	assert.Nil(t, ioutil.WriteFile(invalidPath, []byte("routes: [{method: GET, path: user}]"), 0644))
+	unsupportedPath := filepath.Join(dir, "mocks.txt")
+	assert.Nil(t, ioutil.WriteFile(unsupportedPath, []byte("routes: []"), 0644))
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{args: nil, err: "usage"},
+		{args: []string{"serve"}, err: "--config is required"},
+		{args: []string{"serve", "--config", filepath.Join(dir, "missing.yaml")}, err: "no such file"},
+		{args: []string{"serve", "--config", invalidPath}, err: `routes[0]: path "user" must start with /`},
+		{args: []string{"serve", "--config", unsupportedPath}, err: `unsupported config format ".txt"`},
+	}
+
+	for _, test := range tests {
+		err := run(test.args, &syncBuffer{}, nil)
+		if assert.NotNil(t, err, test.args) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Config is a mock server described in a YAML, JSON or TOML file:
+//
+//	address: 127.0.0.1:8080
+//	routes:
+//	  - method: GET
+//	    path: /user/:id
+//	    statusCode: 200
+//	    header: {Content-Type: [application/json]}
+//	    jsonBody: {id: 1, name: kalt}
+//	    timeout: 250ms
+type Config struct {
+	Address string           `json:"address,omitempty"`
+	Routes  []StubDefinition `json:"routes"`
+}
+
+// LoadError points at the place in a config file that could not be loaded.
+// Line is zero when the problem has no single location.
+type LoadError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadConfig reads a YAML, JSON or TOML Config, picking the format from the
+// file extension. Errors are *LoadError values.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := ParseConfig(path, data)
+	return config, err
+}
+
+// LoadRoutes reads the routes of a config file into the map
+// NewMultiRouteServer takes.
+func LoadRoutes(path string) (map[Route][]ResponseRuleOption, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, routeResponseOptions, err := ParseConfig(path, data)
+	return routeResponseOptions, err
+}
+
+// ParseConfig is LoadConfig for content already read from path, which names
+// the file in errors and picks the format. It also returns the routes, as
+// validating the config builds them anyway.
+func ParseConfig(path string, data []byte) (*Config, map[Route][]ResponseRuleOption, error) {
+	config, positions, err := parseConfig(data, filepath.Ext(path))
+	if err != nil {
+		return nil, nil, positions.loadError(path, err)
+	}
+
+	routeResponseOptions, err := config.RouteResponseOptions()
+	if err != nil {
+		return nil, nil, positions.loadError(path, err)
+	}
+	return config, routeResponseOptions, nil
+}
+
+// RouteResponseOptions converts the routes into the map NewMultiRouteServer
+// takes.
+func (c *Config) RouteResponseOptions() (map[Route][]ResponseRuleOption, error) {
+	routeResponseOptions := make(map[Route][]ResponseRuleOption, len(c.Routes))
+	for i, definition := range c.Routes {
+		options, err := definition.ResponseRuleOptions()
+		if err != nil {
+			return nil, routeError{index: i, err: err}
+		}
+
+		route := definition.Route()
+		if _, ok := routeResponseOptions[route]; ok {
+			return nil, routeError{index: i, err: fmt.Errorf("duplicate route %s", route)}
+		}
+		routeResponseOptions[route] = options
+	}
+	return routeResponseOptions, nil
+}
+
+type routeError struct {
+	index int
+	err   error
+}
+
+func (e routeError) Error() string {
+	return fmt.Sprintf("routes[%d]: %v", e.index, e.err)
+}
+
+func parseConfig(data []byte, extension string) (*Config, *sourcePositions, error) {
+	var document []byte
+	var positions *sourcePositions
+	var err error
+
+	switch strings.ToLower(extension) {
+	case ".json":
+		document, positions, err = parseJSONSource(data)
+	case ".yaml", ".yml":
+		document, positions, err = parseYAMLSource(data)
+	case ".toml":
+		document, positions, err = parseTOMLSource(data)
+	default:
+		return nil, nil, fmt.Errorf("unsupported config format %q", extension)
+	}
+	if err != nil {
+		return nil, positions, err
+	}
+
+	config, err := decodeConfig(document)
+	return config, positions, err
+}
+
+// decodeConfig decodes route by route so errors can name the route and field
+// at fault. All formats are converted to JSON first to share one set of field
+// names.
+func decodeConfig(document []byte) (*Config, error) {
+	var raw struct {
+		Address string            `json:"address"`
+		Routes  []json.RawMessage `json:"routes"`
+	}
+	if err := strictUnmarshal(document, &raw); err != nil {
+		return nil, err
+	}
+
+	config := &Config{Address: raw.Address, Routes: make([]StubDefinition, len(raw.Routes))}
+	for i, route := range raw.Routes {
+		if err := strictUnmarshal(route, &config.Routes[i]); err != nil {
+			return nil, routeError{index: i, err: err}
+		}
+	}
+	return config, nil
+}
+
+func strictUnmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+
+	if typeError, ok := err.(*json.UnmarshalTypeError); ok {
+		return fieldError{field: typeError.Field, err: fmt.Errorf("%s must be %s, not %s", typeError.Field, typeError.Type, typeError.Value)}
+	}
+	if err != nil && strings.HasPrefix(err.Error(), `json: unknown field "`) {
+		field := strings.TrimSuffix(strings.TrimPrefix(err.Error(), `json: unknown field "`), `"`)
+		return fieldError{field: field, err: fmt.Errorf("unknown field %q", field)}
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+package aduket
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	path := writeConfig(t, "mocks.yml", `
+address: 127.0.0.1:9999
+routes:
+  - method: get
+    path: /user
+    statusCode: 202
+    header: {X-Mock: [aduket]}
+    stringBody: kalt
+    timeout: 1ms
+  - method: GET
+    path: /broken
+    corruptedBody: true
+`)
+
+	config, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:9999", config.Address)
+	assert.Len(t, config.Routes, 2)
+
+	routeResponseOptions, err := config.RouteResponseOptions()
+	assert.Nil(t, err)
+
+	rule := createResponseRule(routeResponseOptions[Route{HttpMethod: http.MethodGet, Path: "/user"}])
+	assert.Equal(t, http.StatusAccepted, rule.statusCode)
+	assert.Equal(t, "aduket", rule.header.Get("X-Mock"))
+	assert.Equal(t, responseBody("kalt"), rule.body)
+	assert.Equal(t, time.Millisecond, rule.timeout)
+
+	assert.True(t, createResponseRule(routeResponseOptions[Route{HttpMethod: http.MethodGet, Path: "/broken"}]).sendCorruptedBody)
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeConfig(t, "mocks.json", `{"routes": [{"method": "POST", "path": "/user", "jsonBody": {"id": 1}}]}`)
+
+	config, err := LoadConfig(path)
+	assert.Nil(t, err)
+
+	routeResponseOptions, err := config.RouteResponseOptions()
+	assert.Nil(t, err)
+	rule := createResponseRule(routeResponseOptions[Route{HttpMethod: http.MethodPost, Path: "/user"}])
+	assert.Equal(t, responseBody(`{"id":1}`), rule.body)
+}
+
+func TestLoadRoutesTOML(t *testing.T) {
+	path := writeConfig(t, "mocks.toml", `
+[[routes]]
+method = "GET"
+path = "/user/:id"
+statusCode = 200
+timeout = "5ms"
+
+[routes.header]
+Content-Type = ["application/json"]
+
+[routes.jsonBody]
+id = 1
+name = "kalt"
+
+[[routes]]
+method = "DELETE"
+path = "/user/:id"
+corruptedBody = true
+`)
+
+	routeResponseOptions, err := LoadRoutes(path)
+	assert.Nil(t, err)
+	assert.Len(t, routeResponseOptions, 2)
+
+	rule := createResponseRule(routeResponseOptions[Route{HttpMethod: http.MethodGet, Path: "/user/:id"}])
+	assert.Equal(t, "application/json", rule.header.Get("Content-Type"))
+	assert.JSONEq(t, `{"id": 1, "name": "kalt"}`, string(rule.body))
+	assert.Equal(t, 5*time.Millisecond, rule.timeout)
+	assert.True(t, createResponseRule(routeResponseOptions[Route{HttpMethod: http.MethodDelete, Path: "/user/:id"}]).sendCorruptedBody)
+}
+
+func TestLoadRoutesServesMultiRouteServer(t *testing.T) {
+	path := writeConfig(t, "mocks.yaml", `
+routes:
+  - {method: GET, path: /user, statusCode: 200, stringBody: kalt}
+  - {method: POST, path: /user, statusCode: 201}
+`)
+	routeResponseOptions, err := LoadRoutes(path)
+	assert.Nil(t, err)
+
+	server, requestRecorder := NewMultiRouteServer(routeResponseOptions)
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/user", "application/json", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Len(t, requestRecorder[Route{HttpMethod: http.MethodPost, Path: "/user"}].Requests, 1)
+}
+
+func TestLoadRoutesErrorLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		message string
+	}{
+		{
+			name:    "mocks.yaml",
+			content: "routes:\n  - {method: GET, path: /user}\n  - method: get\n    path: /user\n",
+			line:    3,
+			message: "routes[1]: duplicate route GET /user",
+		},
+		{
+			name:    "mocks.yaml",
+			content: "routes:\n  - method: GET\n    path: /user\n    timeout: soon\n",
+			line:    4,
+			message: `routes[0]: invalid timeout: time: invalid duration "soon"`,
+		},
+		{
+			name:    "mocks.yaml",
+			content: "routes:\n  - method: GET\n    path: /user\n    stautsCode: 200\n",
+			line:    4,
+			message: `routes[0]: unknown field "stautsCode"`,
+		},
+		{
+			name:    "mocks.yaml",
+			content: "routes:\n  - method: GET\n    path: \"/user\n",
+			line:    3,
+			message: "found unexpected end of stream",
+		},
+		{
+			name:    "mocks.json",
+			content: "{\n  \"routes\": [\n    {\"method\": \"GET\", \"path\": \"/user\"},\n    {\n      \"method\": \"GET\",\n      \"path\": \"/book\",\n      \"statusCode\": \"ok\"\n    }\n  ]\n}",
+			line:    7,
+			message: "routes[1]: statusCode must be int, not string",
+		},
+		{
+			name:    "mocks.json",
+			content: "{\n  \"routes\": [\n    {\"method\": \"GET\"\n  ]\n}",
+			line:    4,
+			message: "invalid character ']' after object key:value pair",
+		},
+		{
+			name:    "mocks.toml",
+			content: "[[routes]]\nmethod = \"GET\"\npath = \"/user\"\n\n[[routes]]\nmethod = \"GET\"\npath = \"book\"\n",
+			line:    7,
+			message: `routes[1]: path "book" must start with /`,
+		},
+		{
+			name:    "mocks.toml",
+			content: "[[routes]]\nmethod = \"GET\"\npath = /user\n",
+			line:    3,
+			message: "expected value but found '/' instead",
+		},
+	}
+
+	for _, test := range tests {
+		path := writeConfig(t, test.name, test.content)
+
+		_, err := LoadRoutes(path)
+		var loadError *LoadError
+		if assert.True(t, errors.As(err, &loadError), test.content) {
+			assert.Equal(t, path, loadError.File)
+			assert.Equal(t, test.line, loadError.Line, test.content)
+			assert.Contains(t, loadError.Error(), test.message)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	config, routeResponseOptions, err := ParseConfig("mocks.yaml", []byte("routes:\n  - method: GET\n    path: /user\n"))
+	assert.Nil(t, err)
+	assert.Len(t, config.Routes, 1)
+	assert.Contains(t, routeResponseOptions, Route{HttpMethod: http.MethodGet, Path: "/user"})
+
+	_, _, err = ParseConfig("mocks.yaml", []byte("routes:\n  - method: GET\n    path: user\n"))
+	var loadError *LoadError
+	if assert.True(t, errors.As(err, &loadError)) {
+		assert.Equal(t, "mocks.yaml", loadError.File)
+		assert.Equal(t, 3, loadError.Line)
+	}
+}
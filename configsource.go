@@ -0,0 +1,292 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// sourcePositions remembers where the keys and routes of a config file are,
+// so load errors can carry a line number.
+type sourcePositions struct {
+	keys   map[string]int
+	routes []routePosition
+}
+
+type routePosition struct {
+	line   int
+	fields map[string]int
+}
+
+// lineError is a syntax error reported by a parser at a known line.
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e lineError) Error() string {
+	return e.err.Error()
+}
+
+func newSourcePositions() *sourcePositions {
+	return &sourcePositions{keys: make(map[string]int)}
+}
+
+func (p *sourcePositions) loadError(file string, err error) *LoadError {
+	loadError := &LoadError{File: file, Err: err}
+
+	var syntaxError lineError
+	var routeErr routeError
+	var fieldErr fieldError
+	switch {
+	case errors.As(err, &syntaxError):
+		loadError.Line, loadError.Err = syntaxError.line, syntaxError.err
+	case errors.As(err, &routeErr):
+		loadError.Line = p.routeLine(routeErr.index, errorField(routeErr.err))
+	case errors.As(err, &fieldErr):
+		loadError.Line = p.keyLine(fieldErr.field)
+	}
+	return loadError
+}
+
+func (p *sourcePositions) keyLine(key string) int {
+	if p == nil {
+		return 0
+	}
+	return p.keys[key]
+}
+
+func (p *sourcePositions) routeLine(index int, field string) int {
+	if p == nil || index >= len(p.routes) {
+		return p.keyLine("routes")
+	}
+	route := p.routes[index]
+	if line, ok := route.fields[strings.SplitN(field, ".", 2)[0]]; ok {
+		return line
+	}
+	return route.line
+}
+
+func errorField(err error) string {
+	var fieldErr fieldError
+	if errors.As(err, &fieldErr) {
+		return fieldErr.field
+	}
+	return ""
+}
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func parseYAMLSource(data []byte) ([]byte, *sourcePositions, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+			line, _ := strconv.Atoi(match[1])
+			return nil, nil, lineError{line: line, err: errors.New(match[2])}
+		}
+		return nil, nil, err
+	}
+
+	positions := newSourcePositions()
+	if len(root.Content) == 0 {
+		return []byte("{}"), positions, nil
+	}
+
+	document := root.Content[0]
+	if document.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(document.Content); i += 2 {
+			key, value := document.Content[i], document.Content[i+1]
+			positions.keys[key.Value] = key.Line
+			if key.Value != "routes" || value.Kind != yaml.SequenceNode {
+				continue
+			}
+			for _, route := range value.Content {
+				position := routePosition{line: route.Line, fields: make(map[string]int)}
+				if route.Kind == yaml.MappingNode {
+					for j := 0; j+1 < len(route.Content); j += 2 {
+						position.fields[route.Content[j].Value] = route.Content[j].Line
+					}
+				}
+				positions.routes = append(positions.routes, position)
+			}
+		}
+	}
+
+	var value interface{}
+	if err := document.Decode(&value); err != nil {
+		return nil, positions, err
+	}
+	converted, err := json.Marshal(value)
+	if err != nil {
+		return nil, positions, lineError{line: document.Line, err: err}
+	}
+	return converted, positions, nil
+}
+
+func parseJSONSource(data []byte) ([]byte, *sourcePositions, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			return nil, nil, lineError{line: lineAt(data, syntaxError.Offset), err: err}
+		}
+		return nil, nil, err
+	}
+
+	return data, jsonPositions(data), nil
+}
+
+// jsonPositions walks the tokens of an already validated document, noting the
+// lines of the top level keys, the routes and their fields.
+func jsonPositions(data []byte) *sourcePositions {
+	positions := newSourcePositions()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return positions
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return positions
+		}
+		key, _ := token.(string)
+		positions.keys[key] = lineAt(data, decoder.InputOffset())
+
+		if key != "routes" {
+			if skipJSONValue(decoder) != nil {
+				return positions
+			}
+			continue
+		}
+
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			return positions
+		}
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return positions
+			}
+			position := routePosition{line: lineAt(data, decoder.InputOffset()-1), fields: make(map[string]int)}
+			if token == json.Delim('{') {
+				for decoder.More() {
+					field, err := decoder.Token()
+					if err != nil {
+						return positions
+					}
+					name, _ := field.(string)
+					position.fields[name] = lineAt(data, decoder.InputOffset())
+					if skipJSONValue(decoder) != nil {
+						return positions
+					}
+				}
+				if _, err := decoder.Token(); err != nil {
+					return positions
+				}
+			} else if delim, ok := token.(json.Delim); ok && delim == '[' {
+				return positions
+			}
+			positions.routes = append(positions.routes, position)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return positions
+		}
+	}
+	return positions
+}
+
+func skipJSONValue(decoder *json.Decoder) error {
+	var value json.RawMessage
+	return decoder.Decode(&value)
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+var (
+	tomlRoutesHeader = regexp.MustCompile(`^\s*\[\[\s*"?routes"?\s*\]\]`)
+	tomlTableHeader  = regexp.MustCompile(`^\s*\[\s*"?routes"?\s*\.\s*"?([^\]".]+)"?\s*\]`)
+	tomlKey          = regexp.MustCompile(`^\s*"?([A-Za-z0-9_-]+)"?\s*=`)
+)
+
+func parseTOMLSource(data []byte) ([]byte, *sourcePositions, error) {
+	var value map[string]interface{}
+	if err := toml.Unmarshal(data, &value); err != nil {
+		var parseError toml.ParseError
+		if errors.As(err, &parseError) {
+			return nil, nil, lineError{line: parseError.Position.Line, err: errors.New(parseError.Message)}
+		}
+		return nil, nil, err
+	}
+
+	converted, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return converted, tomlPositions(data), nil
+}
+
+// tomlPositions scans for [[routes]] tables. Routes written as an inline
+// array only get the line of the routes key.
+func tomlPositions(data []byte) *sourcePositions {
+	positions := newSourcePositions()
+	var route *routePosition
+	inTable := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		switch {
+		case tomlRoutesHeader.MatchString(text):
+			positions.routes = append(positions.routes, routePosition{line: line, fields: make(map[string]int)})
+			route = &positions.routes[len(positions.routes)-1]
+			inTable = false
+			if _, ok := positions.keys["routes"]; !ok {
+				positions.keys["routes"] = line
+			}
+		case tomlTableHeader.MatchString(text):
+			if route != nil {
+				route.fields[tomlTableHeader.FindStringSubmatch(text)[1]] = line
+			}
+			inTable = true
+		case strings.HasPrefix(strings.TrimSpace(text), "["):
+			route, inTable = nil, true
+		case tomlKey.MatchString(text) && !inTable:
+			key := tomlKey.FindStringSubmatch(text)[1]
+			if route != nil {
+				route.fields[key] = line
+			} else {
+				positions.keys[key] = line
+			}
+		}
+	}
+	return positions
+}
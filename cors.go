@@ -0,0 +1,206 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// CORSPolicy describes how a route answers cross-origin requests. Empty
+// AllowMethods defaults to the methods registered on the path and empty
+// AllowHeaders reflects whatever headers the preflight asked for.
+type CORSPolicy struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// PreflightRequest is recorded on the route whose method it asks for. A
+// preflight for a method without a route is recorded on every CORS route of
+// the path and answered without Access-Control-Allow-Methods, so the browser
+// rejects the actual request.
+type PreflightRequest struct {
+	Origin  string
+	Method  string
+	Headers []string
+}
+
+type preflightTarget struct {
+	recorder *RequestRecorder
+	policy   *CORSPolicy
+}
+
+func registerPreflightHandlers(e *echo.Echo, routeResponseRules map[Route]responseRule, requestRecorder map[Route]*RequestRecorder) {
+	pathTargets := make(map[string]map[string]preflightTarget)
+	// A route with a host comes after and replaces the same route without one.
+	for _, route := range sortedRuleRoutes(routeResponseRules) {
+		rule := routeResponseRules[route]
+		if route.HttpMethod == http.MethodOptions {
+			pathTargets[route.Path] = nil
+			continue
+		}
+		if rule.cors == nil {
+			continue
+		}
+
+		targets, ok := pathTargets[route.Path]
+		if ok && targets == nil {
+			continue
+		}
+		if !ok {
+			targets = make(map[string]preflightTarget)
+			pathTargets[route.Path] = targets
+		}
+		targets[route.HttpMethod] = preflightTarget{recorder: requestRecorder[route], policy: rule.cors}
+	}
+
+	for path, targets := range pathTargets {
+		if len(targets) == 0 {
+			continue
+		}
+		e.Add(http.MethodOptions, path, preflightHandler(targets))
+	}
+}
+
+func preflightHandler(targets map[string]preflightTarget) echo.HandlerFunc {
+	methods := make([]string, 0, len(targets))
+	for method := range targets {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	return func(ctx echo.Context) error {
+		request := ctx.Request()
+		requestedMethod := request.Header.Get(echo.HeaderAccessControlRequestMethod)
+		if requestedMethod == "" {
+			ctx.Response().Header().Set("Allow", strings.Join(append(methods, http.MethodOptions), ", "))
+			return ctx.NoContent(http.StatusNoContent)
+		}
+
+		preflight := &PreflightRequest{
+			Origin:  request.Header.Get(echo.HeaderOrigin),
+			Method:  requestedMethod,
+			Headers: splitHeaderList(request.Header.Get(echo.HeaderAccessControlRequestHeaders)),
+		}
+
+		header := ctx.Response().Header()
+		header.Add(echo.HeaderVary, echo.HeaderOrigin)
+
+		target, ok := targets[requestedMethod]
+		if !ok {
+			for _, method := range methods {
+				targets[method].recorder.addPreflight(preflight)
+			}
+			targets[methods[0]].policy.setAllowOrigin(header, preflight.Origin)
+			return ctx.NoContent(http.StatusNoContent)
+		}
+
+		target.recorder.addPreflight(preflight)
+		if !target.policy.setAllowOrigin(header, preflight.Origin) {
+			return ctx.NoContent(http.StatusNoContent)
+		}
+
+		allowMethods := target.policy.AllowMethods
+		if len(allowMethods) == 0 {
+			allowMethods = methods
+		}
+		header.Set(echo.HeaderAccessControlAllowMethods, strings.Join(allowMethods, ", "))
+
+		if len(target.policy.AllowHeaders) != 0 {
+			header.Set(echo.HeaderAccessControlAllowHeaders, strings.Join(target.policy.AllowHeaders, ", "))
+		} else if len(preflight.Headers) != 0 {
+			header.Set(echo.HeaderAccessControlAllowHeaders, strings.Join(preflight.Headers, ", "))
+		}
+
+		if target.policy.MaxAge > 0 {
+			header.Set(echo.HeaderAccessControlMaxAge, strconv.Itoa(int(target.policy.MaxAge.Seconds())))
+		}
+
+		return ctx.NoContent(http.StatusNoContent)
+	}
+}
+
+// withDefaultCORS returns rules with policy set on the routes that have no
+// CORS policy of their own.
+func withDefaultCORS(routeResponseRules map[Route]responseRule, policy *CORSPolicy) map[Route]responseRule {
+	if policy == nil {
+		return routeResponseRules
+	}
+
+	rules := make(map[Route]responseRule, len(routeResponseRules))
+	for route, rule := range routeResponseRules {
+		if rule.cors == nil && route.HttpMethod != http.MethodOptions {
+			rule.cors = policy
+		}
+		rules[route] = rule
+	}
+	return rules
+}
+
+func (c *CORSPolicy) applyActualRequestHeaders(ctx echo.Context) {
+	header := ctx.Response().Header()
+	header.Add(echo.HeaderVary, echo.HeaderOrigin)
+
+	if !c.setAllowOrigin(header, ctx.Request().Header.Get(echo.HeaderOrigin)) {
+		return
+	}
+	if len(c.ExposeHeaders) != 0 {
+		header.Set(echo.HeaderAccessControlExposeHeaders, strings.Join(c.ExposeHeaders, ", "))
+	}
+}
+
+func (c *CORSPolicy) setAllowOrigin(header http.Header, origin string) bool {
+	if origin == "" {
+		return false
+	}
+
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" && !c.AllowCredentials {
+			header.Set(echo.HeaderAccessControlAllowOrigin, "*")
+			return true
+		}
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			header.Set(echo.HeaderAccessControlAllowOrigin, origin)
+			if c.AllowCredentials {
+				header.Set(echo.HeaderAccessControlAllowCredentials, "true")
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitHeaderList(s string) []string {
+	var headers []string
+	for _, header := range strings.Split(s, ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
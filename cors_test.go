@@ -0,0 +1,128 @@
+package aduket
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	policy := CORSPolicy{
+		AllowOrigins:     []string{"https://streetbyters.com"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	getRoute := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	postRoute := Route{HttpMethod: http.MethodPost, Path: "/user"}
+
+	server, requestRecorder := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		getRoute:  {CORS(policy)},
+		postRoute: {CORS(policy), StatusCode(http.StatusCreated)},
+	})
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodOptions, server.URL+"/user", http.NoBody)
+	request.Header.Set("Origin", "https://streetbyters.com")
+	request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	request.Header.Set("Access-Control-Request-Headers", "Content-Type, X-Request-ID")
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, "https://streetbyters.com", response.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", response.Header.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type, x-request-id", response.Header.Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "true", response.Header.Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "600", response.Header.Get("Access-Control-Max-Age"))
+
+	tester := &testing.T{}
+	assert.True(t, requestRecorder[postRoute].AssertPreflightEqual(tester, PreflightRequest{
+		Origin:  "https://streetbyters.com",
+		Method:  http.MethodPost,
+		Headers: []string{"content-type", "x-request-id"},
+	}))
+	assert.True(t, requestRecorder[postRoute].AssertNoRequest(tester))
+	assert.True(t, requestRecorder[getRoute].AssertNoPreflight(tester))
+	assert.False(t, tester.Failed())
+
+	request.Header.Set("Origin", "https://evil.com")
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Empty(t, response.Header.Get("Access-Control-Allow-Origin"))
+	assert.Len(t, requestRecorder[postRoute].Preflights, 2)
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	server, _ := NewServer(http.MethodGet, "/user", CORS(CORSPolicy{
+		AllowOrigins:  []string{"*"},
+		ExposeHeaders: []string{"X-Total-Count"},
+	}))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/user", http.NoBody)
+	request.Header.Set("Origin", "https://streetbyters.com")
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "*", response.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "X-Total-Count", response.Header.Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, "Origin", response.Header.Get("Vary"))
+}
+
+func TestCORSPreflightForMethodWithoutRoute(t *testing.T) {
+	policy := CORSPolicy{AllowOrigins: []string{"https://streetbyters.com"}}
+	getRoute := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	postRoute := Route{HttpMethod: http.MethodPost, Path: "/user"}
+
+	server, requestRecorder := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		getRoute:  {CORS(policy)},
+		postRoute: {CORS(policy)},
+	})
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodOptions, server.URL+"/user", http.NoBody)
+	request.Header.Set("Origin", "https://streetbyters.com")
+	request.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Empty(t, response.Header.Get("Access-Control-Allow-Methods"))
+
+	expected := PreflightRequest{Origin: "https://streetbyters.com", Method: http.MethodDelete}
+	tester := &testing.T{}
+	assert.True(t, requestRecorder[getRoute].AssertPreflightEqual(tester, expected))
+	assert.True(t, requestRecorder[postRoute].AssertPreflightEqual(tester, expected))
+	assert.False(t, tester.Failed())
+}
+
+func TestServeCORS(t *testing.T) {
+	getRoute := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	deleteRoute := Route{HttpMethod: http.MethodDelete, Path: "/user"}
+
+	server, requestRecorder := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		getRoute:    {},
+		deleteRoute: {CORS(CORSPolicy{AllowOrigins: []string{"https://admin.streetbyters.com"}})},
+	}, ServeCORS(CORSPolicy{AllowOrigins: []string{"https://streetbyters.com"}}))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodOptions, server.URL+"/user", http.NoBody)
+	request.Header.Set("Origin", "https://streetbyters.com")
+	request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://streetbyters.com", response.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "DELETE, GET", response.Header.Get("Access-Control-Allow-Methods"))
+
+	request.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Empty(t, response.Header.Get("Access-Control-Allow-Origin"))
+
+	tester := &testing.T{}
+	assert.True(t, requestRecorder[getRoute].AssertPreflightEqual(tester, PreflightRequest{Origin: "https://streetbyters.com", Method: http.MethodGet}))
+	assert.False(t, tester.Failed())
+}
@@ -17,8 +17,8 @@ func TestGetTotalPrice(t *testing.T) {
 	)
 	defer productServer.Close()
 
-	cartRoute := aduket.Route{http.MethodGet, "/user/:userid/cart"}
-	discountRoute := aduket.Route{http.MethodGet, "/user/:userid/discount"}
+	cartRoute := aduket.Route{HttpMethod: http.MethodGet, Path: "/user/:userid/cart"}
+	discountRoute := aduket.Route{HttpMethod: http.MethodGet, Path: "/user/:userid/discount"}
 
 	cartServer, cartServerRequestRecorder := aduket.NewMultiRouteServer(
 		map[aduket.Route][]aduket.ResponseRuleOption{
@@ -0,0 +1,115 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+)
+
+// Handler is NewServer without a listener: it returns the handler the server
+// would run, for tests that call ServeHTTP directly.
+func Handler(httpMethod, path string, responseRuleOptions ...ResponseRuleOption) (http.Handler, *RequestRecorder) {
+	route := Route{HttpMethod: httpMethod, Path: path}
+
+	handler, requestRecorder := MultiRouteHandler(map[Route][]ResponseRuleOption{route: responseRuleOptions})
+	return handler, requestRecorder[route]
+}
+
+func MultiRouteHandler(routeResponseOptions map[Route][]ResponseRuleOption) (http.Handler, map[Route]*RequestRecorder) {
+	return newRouteHandler(createRouteResponseRules(routeResponseOptions), nil, nil, nil)
+}
+
+// Transport serves the route in-process for clients built as
+// &http.Client{Transport: transport}. Requests to any host reach the route.
+func Transport(httpMethod, path string, responseRuleOptions ...ResponseRuleOption) (http.RoundTripper, *RequestRecorder) {
+	handler, requestRecorder := Handler(httpMethod, path, responseRuleOptions...)
+	return HandlerTransport(handler), requestRecorder
+}
+
+func MultiRouteTransport(routeResponseOptions map[Route][]ResponseRuleOption) (http.RoundTripper, map[Route]*RequestRecorder) {
+	handler, requestRecorder := MultiRouteHandler(routeResponseOptions)
+	return HandlerTransport(handler), requestRecorder
+}
+
+// HandlerTransport sends requests straight to handler, so a Handler or a
+// *Server can also be reached through an http.Client. The response is
+// buffered; handlers that hijack the connection are not supported. When the
+// client gives up, the handler sees its request context canceled and
+// RoundTrip returns once the handler did, so nothing is recorded afterwards.
+func HandlerTransport(handler http.Handler) http.RoundTripper {
+	return handlerTransport{handler: handler}
+}
+
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	serverRequest := request.Clone(request.Context())
+	serverRequest.URL = &url.URL{Path: request.URL.Path, RawPath: request.URL.RawPath, RawQuery: request.URL.RawQuery}
+	serverRequest.RequestURI = request.URL.RequestURI()
+	serverRequest.RemoteAddr = "192.0.2.1:1234"
+	serverRequest.Proto, serverRequest.ProtoMajor, serverRequest.ProtoMinor = "HTTP/1.1", 1, 1
+	if serverRequest.Host == "" {
+		serverRequest.Host = request.URL.Host
+	}
+	if serverRequest.Body == nil {
+		serverRequest.Body = http.NoBody
+	}
+
+	recorder := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t.handler.ServeHTTP(recorder, serverRequest)
+	}()
+
+	// Give up like a real transport when the client times out or cancels,
+	// e.g. while a Timeout option is delaying the response.
+	select {
+	case <-done:
+	case <-request.Context().Done():
+		<-done
+		return nil, request.Context().Err()
+	}
+
+	response := recorder.Result()
+	response.Request = request
+	if request.Method == http.MethodHead {
+		return response, nil
+	}
+
+	response.ContentLength = int64(recorder.Body.Len())
+	if declared, err := strconv.ParseInt(response.Header.Get("Content-Length"), 10, 64); err == nil && declared > response.ContentLength {
+		response.Body = io.NopCloser(io.MultiReader(bytes.NewReader(recorder.Body.Bytes()), errorReader{io.ErrUnexpectedEOF}))
+	}
+	return response, nil
+}
+
+// errorReader fails reads the way a connection closed mid-body does.
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,95 @@
+package aduket
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	handler, requestRecorder := Handler(http.MethodPost, "/user/:id", StatusCode(http.StatusCreated), JSONBody(User{ID: 1, Name: "kalt"}))
+
+	request := httptest.NewRequest(http.MethodPost, "/user/1?notify=true", strings.NewReader(`{"name":"kalt"}`))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Equal(t, string(jsonMarshal(User{ID: 1, Name: "kalt"})), response.Body.String())
+	requestRecorder.AssertParamEqual(t, "id", "1")
+	requestRecorder.AssertQueryParamEqual(t, "notify", []string{"true"})
+	requestRecorder.AssertStringBodyEqual(t, `{"name":"kalt"}`)
+	assert.Equal(t, http.StatusCreated, requestRecorder.Requests[0].StatusCode)
+}
+
+func TestTransport(t *testing.T) {
+	transport, requestRecorder := MultiRouteTransport(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/user"}: {
+			StringBody("kalt"),
+			Header(http.Header{"X-Served-By": []string{"aduket"}}),
+		},
+		{HttpMethod: http.MethodHead, Path: "/user"}:   {Header(http.Header{"Content-Length": []string{"4"}})},
+		{HttpMethod: http.MethodGet, Path: "/slow"}:    {Timeout(time.Second)},
+		{HttpMethod: http.MethodGet, Path: "/corrupt"}: {CorruptedBody()},
+	})
+	client := &http.Client{Transport: transport, Timeout: 50 * time.Millisecond}
+
+	response, err := client.Get("http://users.internal/user?page=2")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "kalt", string(body))
+	assert.Equal(t, int64(4), response.ContentLength)
+	assert.Equal(t, "aduket", response.Header.Get("X-Served-By"))
+
+	userRecorder := requestRecorder[Route{HttpMethod: http.MethodGet, Path: "/user"}]
+	userRecorder.AssertQueryParamEqual(t, "page", []string{"2"})
+	assert.Equal(t, "users.internal", userRecorder.Requests[0].Host)
+	assert.Equal(t, "kalt", string(userRecorder.Requests[0].ResponseBody))
+
+	response, err = client.Head("http://users.internal/user")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, int64(4), response.ContentLength)
+
+	response, err = client.Get("http://users.internal/missing")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	started := time.Now()
+	_, err = client.Get("http://users.internal/slow")
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(started), time.Second)
+	slowRecorder := requestRecorder[Route{HttpMethod: http.MethodGet, Path: "/slow"}]
+	assert.Len(t, slowRecorder.Requests, 1)
+
+	response, err = client.Get("http://users.internal/corrupt")
+	assert.Nil(t, err)
+	_, err = ioutil.ReadAll(response.Body)
+	assert.NotNil(t, err)
+}
+
+func TestHandlerTransportSharesRecorder(t *testing.T) {
+	handler, requestRecorder := Handler(http.MethodGet, "/user", StringBody("kalt"))
+	client := &http.Client{Transport: HandlerTransport(handler)}
+
+	_, err := client.Get("http://example.com/user")
+	assert.Nil(t, err)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user", nil))
+
+	assert.Len(t, requestRecorder.Requests, 2)
+
+	server := NewManagedServer(map[Route][]ResponseRuleOption{{HttpMethod: http.MethodGet, Path: "/user"}: {StringBody("managed")}})
+	defer server.Close()
+
+	response, err := (&http.Client{Transport: HandlerTransport(server)}).Get("http://example.com/user")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, "managed", string(body))
+	assert.Len(t, server.Recorder(Route{HttpMethod: http.MethodGet, Path: "/user"}).Requests, 1)
+}
@@ -0,0 +1,380 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo"
+)
+
+// HAR is an HTTP Archive 1.2 document, trimmed to the fields aduket reads
+// and writes.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []HARNameValue `json:"params,omitempty"`
+	Text     string         `json:"text"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARTimings are in milliseconds. aduket only knows the time spent producing
+// the response, which it reports as wait.
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HAROption configures HAR export.
+type HAROption func(redacted map[string]bool)
+
+// HARKeepHeaders writes the given headers to the HAR. Authorization, Cookie,
+// Set-Cookie and Proxy-Authorization, along with the cookies parsed from them,
+// are left out unless kept, as in recorded cassettes.
+func HARKeepHeaders(names ...string) HAROption {
+	return func(redacted map[string]bool) {
+		for _, name := range names {
+			delete(redacted, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// NewHAR converts recorded requests and their responses into HAR entries.
+func NewHAR(requests []*RecordedRequest, options ...HAROption) *HAR {
+	redacted := credentialHeaders()
+	for _, option := range options {
+		option(redacted)
+	}
+
+	har := &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "aduket", Version: "1"},
+		Entries: make([]HAREntry, 0, len(requests)),
+	}}
+	for _, request := range requests {
+		har.Log.Entries = append(har.Log.Entries, newHAREntry(request, redacted))
+	}
+	return har
+}
+
+// HAR exports every request recorded so far.
+func (r *RequestRecorder) HAR(options ...HAROption) *HAR {
+	r.mu.Lock()
+	requests := append([]*RecordedRequest(nil), r.Requests...)
+	r.mu.Unlock()
+
+	return NewHAR(requests, options...)
+}
+
+// HAR exports the journal of the server.
+func (s *Server) HAR(options ...HAROption) *HAR {
+	return NewHAR(s.Journal(), options...)
+}
+
+func LoadHAR(path string) (*HAR, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	har := &HAR{}
+	if err := json.Unmarshal(data, har); err != nil {
+		return nil, fmt.Errorf("aduket: invalid HAR %s: %v", path, err)
+	}
+	return har, nil
+}
+
+func (h *HAR) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// NewServerFromHAR serves the responses of a HAR file with one route per
+// method and path. Entries sharing a route are replayed in order, repeating
+// the last one. Entries without a status, such as aborted browser requests,
+// are skipped. Routes don't match on query strings, so entries differing only
+// in their query, such as ?page=1 and ?page=2, are replayed in order too.
+func NewServerFromHAR(path string, serverOptions ...ServerOption) (*httptest.Server, map[Route]*RequestRecorder) {
+	har, err := LoadHAR(path)
+	if err != nil {
+		panic(err)
+	}
+
+	routeResponseOptions, err := har.RouteResponseOptions()
+	if err != nil {
+		panic(err)
+	}
+	return NewMultiRouteServer(routeResponseOptions, serverOptions...)
+}
+
+// RouteResponseOptions converts the entries into the map NewMultiRouteServer
+// takes, as NewServerFromHAR does.
+func (h *HAR) RouteResponseOptions() (map[Route][]ResponseRuleOption, error) {
+	responses := make(map[Route][][]ResponseRuleOption)
+	for i, entry := range h.Log.Entries {
+		if entry.Response.Status == 0 {
+			continue
+		}
+
+		entryURL, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("aduket: HAR entry %d: %v", i, err)
+		}
+		path := entryURL.Path
+		if path == "" {
+			path = "/"
+		}
+
+		options, err := entry.Response.responseRuleOptions()
+		if err != nil {
+			return nil, fmt.Errorf("aduket: HAR entry %d: %v", i, err)
+		}
+
+		route := Route{HttpMethod: entry.Request.Method, Path: path}
+		responses[route] = append(responses[route], options)
+	}
+
+	routeResponseOptions := make(map[Route][]ResponseRuleOption, len(responses))
+	for route, routeResponses := range responses {
+		if len(routeResponses) == 1 {
+			routeResponseOptions[route] = routeResponses[0]
+			continue
+		}
+		routeResponseOptions[route] = []ResponseRuleOption{sequenceOf(routeResponses...)}
+	}
+	return routeResponseOptions, nil
+}
+
+// sequenceOf answers each call with the next of responses, repeating the last
+// one once all were served. Other options of the route are ignored.
+func sequenceOf(responses ...[]ResponseRuleOption) ResponseRuleOption {
+	return func(r *responseRule) {
+		sequence := &responseSequence{}
+		for _, response := range responses {
+			sequence.rules = append(sequence.rules, createResponseRule(response))
+		}
+		r.sequence = sequence
+	}
+}
+
+// Headers describing the transfer of the original body don't apply to the
+// replayed one.
+var harSkippedResponseHeaders = map[string]bool{
+	echo.HeaderContentLength:   true,
+	echo.HeaderContentEncoding: true,
+	"Transfer-Encoding":        true,
+	"Connection":               true,
+}
+
+func (r HARResponse) responseRuleOptions() ([]ResponseRuleOption, error) {
+	header := http.Header{}
+	for _, h := range r.Headers {
+		name := http.CanonicalHeaderKey(h.Name)
+		if harSkippedResponseHeaders[name] {
+			continue
+		}
+		header.Add(name, h.Value)
+	}
+
+	body := []byte(r.Content.Text)
+	if r.Content.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(r.Content.Text)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+
+	options := []ResponseRuleOption{StatusCode(r.Status)}
+	if len(header) != 0 {
+		options = append(options, Header(header))
+	}
+	if len(body) != 0 {
+		options = append(options, ByteBody(body))
+	}
+	return options, nil
+}
+
+func newHAREntry(request *RecordedRequest, redacted map[string]bool) HAREntry {
+	requestHeader := withoutHeaders(request.Header, redacted)
+	responseHeader := withoutHeaders(request.ResponseHeader, redacted)
+
+	scheme := "http"
+	if request.TLS != nil {
+		scheme = "https"
+	}
+	requestURL := url.URL{Scheme: scheme, Host: request.Host, Path: request.Path, RawQuery: request.QueryParams.Encode()}
+
+	httpVersion := request.Protocol
+	if httpVersion == "" {
+		httpVersion = "HTTP/1.1"
+	}
+	wait := float64(request.Duration) / float64(time.Millisecond)
+
+	entry := HAREntry{
+		StartedDateTime: request.ReceivedAt,
+		Time:            wait,
+		Request: HARRequest{
+			Method:      request.Method,
+			URL:         requestURL.String(),
+			HTTPVersion: httpVersion,
+			Cookies:     harCookies((&http.Request{Header: requestHeader}).Cookies()),
+			Headers:     harHeaders(requestHeader),
+			QueryString: harValues(request.QueryParams),
+			HeadersSize: -1,
+			BodySize:    len(request.Body),
+		},
+		Response: HARResponse{
+			Status:      request.StatusCode,
+			StatusText:  http.StatusText(request.StatusCode),
+			HTTPVersion: httpVersion,
+			Cookies:     harCookies((&http.Response{Header: responseHeader}).Cookies()),
+			Headers:     harHeaders(responseHeader),
+			Content: HARContent{
+				Size:     len(request.ResponseBody),
+				MimeType: request.ResponseHeader.Get(echo.HeaderContentType),
+			},
+			RedirectURL: request.ResponseHeader.Get(echo.HeaderLocation),
+			HeadersSize: -1,
+			BodySize:    len(request.ResponseBody),
+		},
+		Timings: HARTimings{Wait: wait},
+	}
+
+	if len(request.Body) != 0 {
+		entry.Request.PostData = &HARPostData{
+			MimeType: request.Header.Get(echo.HeaderContentType),
+			Params:   harValues(request.FormParams),
+			Text:     string(request.Body),
+		}
+	}
+	if utf8.Valid(request.ResponseBody) {
+		entry.Response.Content.Text = string(request.ResponseBody)
+	} else {
+		entry.Response.Content.Text = base64.StdEncoding.EncodeToString(request.ResponseBody)
+		entry.Response.Content.Encoding = "base64"
+	}
+
+	return entry
+}
+
+func withoutHeaders(header http.Header, names map[string]bool) http.Header {
+	kept := header.Clone()
+	for name := range names {
+		kept.Del(name)
+	}
+	return kept
+}
+
+func harHeaders(header http.Header) []HARNameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]HARNameValue, 0, len(header))
+	for _, name := range names {
+		for _, value := range header[name] {
+			pairs = append(pairs, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+func harValues(values url.Values) []HARNameValue {
+	return harHeaders(http.Header(values))
+}
+
+func harCookies(cookies []*http.Cookie) []HARNameValue {
+	pairs := make([]HARNameValue, 0, len(cookies))
+	for _, cookie := range cookies {
+		pairs = append(pairs, HARNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	return pairs
+}
@@ -0,0 +1,142 @@
+package aduket
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestRecorderHAR(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodPost, "/user",
+		StatusCode(http.StatusCreated),
+		JSONBody(User{ID: 1, Name: "kalt"}),
+		Header(http.Header{"Content-Type": []string{"application/json"}}),
+		SetCookie(&http.Cookie{Name: "session", Value: "abc"}),
+		Timeout(5*time.Millisecond),
+	)
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodPost, server.URL+"/user?notify=true", strings.NewReader(`{"name":"kalt"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer secret")
+	request.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	_, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+
+	redacted := requestRecorder.HAR().Log.Entries[0]
+	for _, header := range append(redacted.Request.Headers, redacted.Response.Headers...) {
+		assert.NotContains(t, []string{"Authorization", "Cookie", "Set-Cookie"}, header.Name)
+	}
+	assert.Empty(t, redacted.Request.Cookies)
+	assert.Empty(t, redacted.Response.Cookies)
+
+	har := requestRecorder.HAR(HARKeepHeaders("cookie", "Set-Cookie"))
+	assert.Equal(t, "1.2", har.Log.Version)
+	assert.Len(t, har.Log.Entries, 1)
+
+	entry := har.Log.Entries[0]
+	assert.Equal(t, http.MethodPost, entry.Request.Method)
+	assert.Equal(t, server.URL+"/user?notify=true", entry.Request.URL)
+	assert.Equal(t, "HTTP/1.1", entry.Request.HTTPVersion)
+	assert.Contains(t, entry.Request.Headers, HARNameValue{Name: "Content-Type", Value: "application/json"})
+	assert.Equal(t, []HARNameValue{{Name: "theme", Value: "dark"}}, entry.Request.Cookies)
+	assert.NotContains(t, entry.Request.Headers, HARNameValue{Name: "Authorization", Value: "Bearer secret"})
+	assert.Equal(t, []HARNameValue{{Name: "notify", Value: "true"}}, entry.Request.QueryString)
+	assert.Equal(t, `{"name":"kalt"}`, entry.Request.PostData.Text)
+
+	assert.Equal(t, http.StatusCreated, entry.Response.Status)
+	assert.Equal(t, "Created", entry.Response.StatusText)
+	assert.Equal(t, string(jsonMarshal(User{ID: 1, Name: "kalt"})), entry.Response.Content.Text)
+	assert.Equal(t, "application/json", entry.Response.Content.MimeType)
+	assert.Equal(t, []HARNameValue{{Name: "session", Value: "abc"}}, entry.Response.Cookies)
+	assert.True(t, entry.Time >= 5, "time %v", entry.Time)
+	assert.Equal(t, entry.Time, entry.Timings.Wait)
+	assert.False(t, entry.StartedDateTime.IsZero())
+
+	_, err = json.Marshal(har)
+	assert.Nil(t, err)
+}
+
+func TestServerHARIncludesUnmatchedRequests(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{{HttpMethod: http.MethodGet, Path: "/user"}: {StringBody("kalt")}})
+	defer server.Close()
+
+	_, err := http.Get(server.URL() + "/user")
+	assert.Nil(t, err)
+	_, err = http.Get(server.URL() + "/missing")
+	assert.Nil(t, err)
+
+	entries := server.HAR().Log.Entries
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "kalt", entries[0].Response.Content.Text)
+	assert.Equal(t, http.StatusNotFound, entries[1].Response.Status)
+	assert.Contains(t, entries[1].Response.Content.Text, "Not Found")
+}
+
+func TestNewServerFromHAR(t *testing.T) {
+	harPath := filepath.Join(t.TempDir(), "traffic.har")
+
+	original := NewManagedServer(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/user"}: {
+			sequenceOf(
+				[]ResponseRuleOption{JSONBody(User{ID: 1, Name: "kalt"})},
+				[]ResponseRuleOption{JSONBody(User{ID: 1, Name: "kalt updated"})},
+			),
+		},
+		{HttpMethod: http.MethodGet, Path: "/avatar"}: {ByteBody([]byte{0xff, 0xd8}), Header(http.Header{"Content-Type": []string{"image/jpeg"}})},
+	})
+	for _, path := range []string{"/user", "/user", "/avatar"} {
+		_, err := http.Get(original.URL() + path)
+		assert.Nil(t, err)
+	}
+	original.Close()
+
+	har := original.HAR()
+	har.Log.Entries = append(har.Log.Entries, HAREntry{Request: HARRequest{Method: http.MethodGet, URL: "http://example.com/aborted"}})
+	assert.Nil(t, har.Save(harPath))
+
+	replay, requestRecorder := NewServerFromHAR(harPath)
+	defer replay.Close()
+
+	assert.Len(t, requestRecorder, 2)
+	for _, expected := range []string{"kalt", "kalt updated", "kalt updated"} {
+		response, err := http.Get(replay.URL + "/user")
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(response.Body)
+		assert.Equal(t, string(jsonMarshal(User{ID: 1, Name: expected})), string(body))
+	}
+
+	response, err := http.Get(replay.URL + "/avatar")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, []byte{0xff, 0xd8}, body)
+	assert.Equal(t, "image/jpeg", response.Header.Get("Content-Type"))
+	assert.Len(t, requestRecorder[Route{HttpMethod: http.MethodGet, Path: "/avatar"}].Requests, 1)
+}
+
+func TestNewServerFromHARReplaysPagesInOrder(t *testing.T) {
+	harPath := filepath.Join(t.TempDir(), "pages.har")
+	har := &HAR{Log: HARLog{Entries: []HAREntry{
+		{Request: HARRequest{Method: http.MethodGet, URL: "http://example.com/items?page=1"}, Response: HARResponse{Status: http.StatusOK, Content: HARContent{Text: "first"}}},
+		{Request: HARRequest{Method: http.MethodGet, URL: "http://example.com/items?page=2"}, Response: HARResponse{Status: http.StatusOK, Content: HARContent{Text: "second"}}},
+	}}}
+	assert.Nil(t, har.Save(harPath))
+
+	replay, requestRecorder := NewServerFromHAR(harPath)
+	defer replay.Close()
+
+	assert.Len(t, requestRecorder, 1)
+	for i, expected := range []string{"first", "second"} {
+		response, err := http.Get(replay.URL + "/items?page=" + strconv.Itoa(i+1))
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(response.Body)
+		assert.Equal(t, expected, string(body))
+	}
+}
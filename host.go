@@ -0,0 +1,136 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type hostRouter struct {
+	hosts    map[string]http.Handler
+	fallback http.Handler
+}
+
+func (r *hostRouter) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	if handler, ok := r.hosts[normalizeHost(request.Host)]; ok {
+		handler.ServeHTTP(w, request)
+		return
+	}
+	r.fallback.ServeHTTP(w, request)
+}
+
+// normalizeHost drops the port and trailing dot and lower-cases the name, so
+// API.internal:8080 and api.internal. both become api.internal.
+func normalizeHost(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.TrimPrefix(strings.TrimSuffix(host, "]"), "[")
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// HostClient returns an *http.Client that connects to server whenever a
+// request is for one of hosts, on any port, and dials the others normally.
+// With no hosts every request goes to server. The Host header keeps the name
+// from the URL, so code with baked-in base URLs reaches the routes bound to
+// those hosts. With ServeTLS the certificate of server is verified against
+// the address it listens on rather than the mapped host name.
+func HostClient(server *httptest.Server, hosts ...string) *http.Client {
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = HostDialer(server.Listener.Addr(), hosts...)
+	if server.TLS != nil {
+		transport.DialTLSContext = hostTLSDialer(transport, listenerServerName(server.Listener.Addr()), hosts)
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+func (s *Server) HostClient(hosts ...string) *http.Client {
+	return HostClient(s.httpServer, hosts...)
+}
+
+// HostDialer is the DialContext of HostClient, for code that builds its own
+// http.Transport. Connections to hosts are made to address instead.
+func HostDialer(address net.Addr, hosts ...string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	isMapped := mappedHosts(hosts)
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		var dialer net.Dialer
+		if isMapped(addr) {
+			return dialer.DialContext(ctx, address.Network(), address.String())
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+// hostTLSDialer verifies mapped hosts as serverName and the others under
+// their own name. It reads transport.TLSClientConfig on every dial so the
+// protocols negotiated by the transport are kept.
+func hostTLSDialer(transport *http.Transport, serverName string, hosts []string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	isMapped := mappedHosts(hosts)
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := transport.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		config := &tls.Config{}
+		if transport.TLSClientConfig != nil {
+			config = transport.TLSClientConfig.Clone()
+		}
+		config.ServerName = serverName
+		if !isMapped(addr) {
+			config.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// mappedHosts reports whether the host of addr is one of hosts. With no hosts
+// every address is mapped.
+func mappedHosts(hosts []string) func(addr string) bool {
+	mapped := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		mapped[normalizeHost(host)] = true
+	}
+
+	return func(addr string) bool {
+		return len(mapped) == 0 || mapped[normalizeHost(addr)]
+	}
+}
+
+// listenerServerName is the name the certificates of ServeTLS are issued for
+// that matches address.
+func listenerServerName(address net.Addr) string {
+	if host, _, err := net.SplitHostPort(address.String()); err == nil {
+		return host
+	}
+	return "localhost"
+}
@@ -0,0 +1,136 @@
+package aduket
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hostGet(t *testing.T, client *http.Client, url string) (int, string) {
+	response, err := client.Get(url)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+
+	body, _ := ioutil.ReadAll(response.Body)
+	return response.StatusCode, string(body)
+}
+
+var hostRoutes = map[Route][]ResponseRuleOption{
+	{HttpMethod: http.MethodGet, Path: "/charges", Host: "api.payments.internal"}: {StringBody("charges")},
+	{HttpMethod: http.MethodPost, Path: "/token", Host: "Auth.Internal:443"}:      {StringBody("token")},
+	{HttpMethod: http.MethodGet, Path: "/health"}:                                 {StringBody("ok")},
+	{HttpMethod: http.MethodGet, Path: "/health", Host: "auth.internal"}:          {StringBody("auth ok")},
+}
+
+func TestHostRouting(t *testing.T) {
+	server, requestRecorder := NewMultiRouteServer(hostRoutes)
+	defer server.Close()
+	client := HostClient(server, "api.payments.internal", "auth.internal")
+
+	statusCode, body := hostGet(t, client, "http://api.payments.internal/charges")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "charges", body)
+	chargesRecorder := requestRecorder[Route{HttpMethod: http.MethodGet, Path: "/charges", Host: "api.payments.internal"}]
+	assert.Equal(t, "api.payments.internal", chargesRecorder.Requests[0].Host)
+
+	response, err := client.Post("http://auth.internal:8080/token", "text/plain", strings.NewReader("grant"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	requestRecorder[Route{HttpMethod: http.MethodPost, Path: "/token", Host: "Auth.Internal:443"}].AssertStringBodyEqual(t, "grant")
+
+	statusCode, _ = hostGet(t, client, "http://auth.internal/charges")
+	assert.Equal(t, http.StatusNotFound, statusCode)
+
+	_, body = hostGet(t, client, "http://api.payments.internal/health")
+	assert.Equal(t, "ok", body)
+	_, body = hostGet(t, client, "http://AUTH.internal./health")
+	assert.Equal(t, "auth ok", body)
+	assert.Len(t, requestRecorder[Route{HttpMethod: http.MethodGet, Path: "/health"}].Requests, 1)
+
+	statusCode, _ = hostGet(t, http.DefaultClient, server.URL+"/charges")
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	_, body = hostGet(t, http.DefaultClient, server.URL+"/health")
+	assert.Equal(t, "ok", body)
+}
+
+func TestHostClientDialsOtherHostsNormally(t *testing.T) {
+	server, _ := NewServer(http.MethodGet, "/user", StringBody("mapped"))
+	defer server.Close()
+	other, _ := NewServer(http.MethodGet, "/user", StringBody("other"))
+	defer other.Close()
+
+	_, body := hostGet(t, HostClient(server, "users.internal"), other.URL+"/user")
+	assert.Equal(t, "other", body)
+	_, body = hostGet(t, HostClient(server), "http://anything.example/user")
+	assert.Equal(t, "mapped", body)
+}
+
+func TestHostClientTLS(t *testing.T) {
+	server, _ := NewMultiRouteServer(hostRoutes, ServeTLS(TLSOptions{EnableHTTP2: true}))
+	defer server.Close()
+	other, _ := NewTLSServer(TLSOptions{}, http.MethodGet, "/user", StringBody("other"))
+	defer other.Close()
+
+	client := HostClient(server, "api.payments.internal")
+	response, err := client.Get("https://api.payments.internal/charges")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, "charges", string(body))
+	assert.Equal(t, 2, response.ProtoMajor)
+
+	// Other hosts are verified under their own name, against the roots of
+	// server only.
+	_, err = client.Get(other.URL + "/user")
+	assert.NotNil(t, err)
+
+	wrongSAN, _ := NewMultiRouteServer(hostRoutes, ServeTLS(TLSOptions{Fault: WrongSANCertificate}))
+	defer wrongSAN.Close()
+	_, err = HostClient(wrongSAN, "api.payments.internal").Get("https://api.payments.internal/charges")
+	assert.NotNil(t, err)
+}
+
+func TestHostRoutingInProcessAndManaged(t *testing.T) {
+	transport, _ := MultiRouteTransport(hostRoutes)
+	_, body := hostGet(t, &http.Client{Transport: transport}, "http://auth.internal/health")
+	assert.Equal(t, "auth ok", body)
+
+	server := NewManagedServer(hostRoutes)
+	defer server.Close()
+	client := server.HostClient("api.payments.internal", "auth.internal")
+
+	route := Route{HttpMethod: http.MethodGet, Path: "/refunds", Host: "api.payments.internal"}
+	_, err := server.AddRoute(route, StringBody("refunds"))
+	assert.Nil(t, err)
+	_, body = hostGet(t, client, "http://api.payments.internal/refunds")
+	assert.Equal(t, "refunds", body)
+	statusCode, _ := hostGet(t, client, "http://auth.internal/refunds")
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	assert.Equal(t, "GET api.payments.internal/refunds", route.String())
+
+	assert.Len(t, server.Unmatched().Requests, 1)
+	assert.Equal(t, "auth.internal", server.Unmatched().Requests[0].Host)
+}
+
+func TestHostRoutingCORSPreflight(t *testing.T) {
+	policy := CORSPolicy{AllowOrigins: []string{"https://streetbyters.com"}}
+	server, _ := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/items"}:                        {CORS(policy)},
+		{HttpMethod: http.MethodPost, Path: "/items", Host: "api.internal"}: {CORS(policy)},
+	})
+	defer server.Close()
+	client := HostClient(server)
+
+	for host, allowMethods := range map[string]string{"api.internal": "GET, POST", "other.internal": "GET"} {
+		request, _ := http.NewRequest(http.MethodOptions, "http://"+host+"/items", http.NoBody)
+		request.Header.Set("Origin", "https://streetbyters.com")
+		request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+		response, err := client.Do(request)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNoContent, response.StatusCode)
+		assert.Equal(t, allowMethods, response.Header.Get("Access-Control-Allow-Methods"), host)
+	}
+}
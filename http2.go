@@ -0,0 +1,62 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+)
+
+type connectionSeqKey struct{}
+
+var lastConnectionSeq uint64
+
+// NewH2CServer is NewServer speaking both HTTP/1.1 and cleartext HTTP/2 with
+// prior knowledge. Use H2CClient to talk HTTP/2 to it.
+func NewH2CServer(httpMethod, path string, responseRuleOptions ...ResponseRuleOption) (*httptest.Server, *RequestRecorder) {
+	return NewServerWithOptions(httpMethod, path, responseRuleOptions, ServeH2C())
+}
+
+func NewMultiRouteH2CServer(routeResponseOptions map[Route][]ResponseRuleOption) (*httptest.Server, map[Route]*RequestRecorder) {
+	return NewMultiRouteServer(routeResponseOptions, ServeH2C())
+}
+
+// H2CClient returns an *http.Client that speaks cleartext HTTP/2 only.
+func H2CClient() *http.Client {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+
+	return &http.Client{Transport: &http.Transport{Protocols: protocols}}
+}
+
+// newUnstartedServer numbers every connection so recorders can tell
+// whether requests shared a connection.
+func newUnstartedServer(handler http.Handler) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		return context.WithValue(ctx, connectionSeqKey{}, atomic.AddUint64(&lastConnectionSeq, 1))
+	}
+	return server
+}
+
+func connectionSeq(request *http.Request) uint64 {
+	seq, _ := request.Context().Value(connectionSeqKey{}).(uint64)
+	return seq
+}
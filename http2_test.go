@@ -0,0 +1,61 @@
+package aduket
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestH2CServer(t *testing.T) {
+	server, requestRecorder := NewH2CServer(http.MethodGet, "/user", StringBody("Hello"))
+	defer server.Close()
+
+	response, err := H2CClient().Get(server.URL + "/user")
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/2.0", response.Proto)
+
+	tester := &testing.T{}
+	assert.True(t, requestRecorder.AssertProtocol(tester, "HTTP/2.0"))
+	assert.False(t, tester.Failed())
+
+	_, err = http.Get(server.URL + "/user")
+	assert.Nil(t, err)
+	assert.False(t, requestRecorder.AssertProtocol(tester, "HTTP/2.0"))
+	assert.True(t, tester.Failed())
+}
+
+func TestTLSServerHTTP2Multiplexing(t *testing.T) {
+	ca := NewCertificateAuthority()
+	route := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	server, requestRecorder := NewMultiRouteTLSServer(
+		TLSOptions{CA: ca, EnableHTTP2: true},
+		map[Route][]ResponseRuleOption{route: {StringBody("Hello")}},
+	)
+	defer server.Close()
+
+	client := ca.Client()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Get(server.URL + "/user")
+			assert.Nil(t, err)
+			assert.Equal(t, "HTTP/2.0", response.Proto)
+		}()
+	}
+	wg.Wait()
+
+	requestRecorder[route].AssertProtocol(t, "HTTP/2.0")
+	assert.Len(t, requestRecorder[route].Requests, 10)
+
+	connections := make(map[uint64]bool)
+	for _, request := range requestRecorder[route].Requests {
+		assert.Equal(t, "h2", request.TLS.NegotiatedProtocol)
+		connections[request.ConnectionSeq] = true
+	}
+	assert.Len(t, connections, 1)
+}
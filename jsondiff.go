@@ -0,0 +1,383 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONCompareOption relaxes AssertJSONBodyEqual. Paths are JSONPaths such as
+// $.user.createdAt or $.items[*].id; the leading $. may be left out.
+type JSONCompareOption func(*jsonComparison)
+
+// IgnoreFields skips the values at paths, and everything below them, on both
+// sides.
+func IgnoreFields(paths ...string) JSONCompareOption {
+	return func(c *jsonComparison) {
+		c.ignoredPaths = append(c.ignoredPaths, paths...)
+	}
+}
+
+// UnorderedArrays compares the arrays at paths as multisets. Without paths
+// every array is unordered.
+func UnorderedArrays(paths ...string) JSONCompareOption {
+	return func(c *jsonComparison) {
+		if len(paths) == 0 {
+			c.allArraysUnordered = true
+		}
+		c.unorderedPaths = append(c.unorderedPaths, paths...)
+	}
+}
+
+type jsonComparison struct {
+	ignoredPaths       []string
+	unorderedPaths     []string
+	allArraysUnordered bool
+
+	ignored   [][]jsonPathSegment
+	unordered [][]jsonPathSegment
+}
+
+// jsonDiff compares two JSON documents as values, so key order, whitespace
+// and number formatting don't matter, and returns one line per difference.
+func jsonDiff(expected, actual []byte, options ...JSONCompareOption) ([]string, error) {
+	c := &jsonComparison{}
+	for _, option := range options {
+		option(c)
+	}
+
+	var err error
+	if c.ignored, err = parseJSONPaths(c.ignoredPaths); err != nil {
+		return nil, err
+	}
+	if c.unordered, err = parseJSONPaths(c.unorderedPaths); err != nil {
+		return nil, err
+	}
+
+	expectedValue, err := decodeJSONValue(expected)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected JSON: %v", err)
+	}
+	actualValue, err := decodeJSONValue(actual)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %v", err)
+	}
+	return c.compare(expectedValue, actualValue, nil), nil
+}
+
+func parseJSONPaths(paths []string) ([][]jsonPathSegment, error) {
+	parsed := make([][]jsonPathSegment, 0, len(paths))
+	for _, path := range paths {
+		expression := path
+		if !strings.HasPrefix(expression, "$") {
+			if strings.HasPrefix(expression, "[") {
+				expression = "$" + expression
+			} else {
+				expression = "$." + expression
+			}
+		}
+
+		segments, ok := parseJSONPath(expression)
+		if !ok {
+			return nil, fmt.Errorf("invalid JSON path %q", path)
+		}
+		parsed = append(parsed, segments)
+	}
+	return parsed, nil
+}
+
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+func (c *jsonComparison) compare(expected, actual interface{}, path []jsonPathSegment) []string {
+	if c.matchesAny(c.ignored, path) {
+		return nil
+	}
+
+	location := formatJSONPath(path)
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		if actual, ok := actual.(map[string]interface{}); ok {
+			return c.compareObjects(expected, actual, path)
+		}
+	case []interface{}:
+		if actual, ok := actual.([]interface{}); ok {
+			if c.allArraysUnordered || c.matchesAny(c.unordered, path) {
+				return c.compareUnorderedArrays(expected, actual, path)
+			}
+			return c.compareArrays(expected, actual, path)
+		}
+	case json.Number:
+		if actual, ok := actual.(json.Number); ok {
+			if !jsonNumbersEqual(expected, actual) {
+				return []string{fmt.Sprintf("%s: expected %s, got %s", location, expected, actual)}
+			}
+			return nil
+		}
+	default:
+		if reflect.DeepEqual(expected, actual) {
+			return nil
+		}
+	}
+
+	if jsonKind(expected) != jsonKind(actual) {
+		return []string{fmt.Sprintf("%s: expected %s %s, got %s %s", location, jsonKind(expected), jsonString(expected), jsonKind(actual), jsonString(actual))}
+	}
+	return []string{fmt.Sprintf("%s: expected %s, got %s", location, jsonString(expected), jsonString(actual))}
+}
+
+func (c *jsonComparison) compareObjects(expected, actual map[string]interface{}, path []jsonPathSegment) []string {
+	keys := make([]string, 0, len(expected)+len(actual))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	for key := range actual {
+		if _, ok := expected[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var differences []string
+	for _, key := range keys {
+		childPath := appendJSONPath(path, jsonPathSegment{key: key})
+		if c.matchesAny(c.ignored, childPath) {
+			continue
+		}
+
+		expectedValue, inExpected := expected[key]
+		actualValue, inActual := actual[key]
+		switch {
+		case !inActual:
+			differences = append(differences, fmt.Sprintf("%s: missing, expected %s", formatJSONPath(childPath), jsonString(expectedValue)))
+		case !inExpected:
+			differences = append(differences, fmt.Sprintf("%s: unexpected %s", formatJSONPath(childPath), jsonString(actualValue)))
+		default:
+			differences = append(differences, c.compare(expectedValue, actualValue, childPath)...)
+		}
+	}
+	return differences
+}
+
+func (c *jsonComparison) compareArrays(expected, actual []interface{}, path []jsonPathSegment) []string {
+	var differences []string
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		elementPath := appendJSONPath(path, jsonPathSegment{index: i, isIndex: true})
+		switch {
+		case i >= len(actual):
+			differences = append(differences, fmt.Sprintf("%s: missing, expected %s", formatJSONPath(elementPath), jsonString(expected[i])))
+		case i >= len(expected):
+			differences = append(differences, fmt.Sprintf("%s: unexpected %s", formatJSONPath(elementPath), jsonString(actual[i])))
+		default:
+			differences = append(differences, c.compare(expected[i], actual[i], elementPath)...)
+		}
+	}
+	return differences
+}
+
+// compareUnorderedArrays pairs every expected element with an equal actual
+// one and reports the elements left over on either side.
+func (c *jsonComparison) compareUnorderedArrays(expected, actual []interface{}, path []jsonPathSegment) []string {
+	matched := make([]bool, len(actual))
+
+	var differences []string
+	for _, expectedElement := range expected {
+		found := false
+		for i, actualElement := range actual {
+			elementPath := appendJSONPath(path, jsonPathSegment{index: i, isIndex: true})
+			if !matched[i] && len(c.compare(expectedElement, actualElement, elementPath)) == 0 {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			differences = append(differences, fmt.Sprintf("%s: missing element %s", formatJSONPath(path), jsonString(expectedElement)))
+		}
+	}
+	for i, actualElement := range actual {
+		if !matched[i] {
+			differences = append(differences, fmt.Sprintf("%s: unexpected element %s", formatJSONPath(path), jsonString(actualElement)))
+		}
+	}
+	return differences
+}
+
+func (c *jsonComparison) matchesAny(patterns [][]jsonPathSegment, path []jsonPathSegment) bool {
+	for _, pattern := range patterns {
+		if _, ok := matchJSONPath(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonNumbersEqual(a, b json.Number) bool {
+	x, okX := new(big.Rat).SetString(a.String())
+	y, okY := new(big.Rat).SetString(b.String())
+	if !okX || !okY {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
+
+// matchJSONPath reports whether pattern, which may hold wildcards, names
+// path, and how many wildcards it needed.
+func matchJSONPath(pattern, path []jsonPathSegment) (int, bool) {
+	if len(pattern) != len(path) {
+		return 0, false
+	}
+
+	wildcards := 0
+	for i, segment := range pattern {
+		switch {
+		case segment.wildcard:
+			wildcards++
+		case segment.isIndex != path[i].isIndex,
+			segment.isIndex && segment.index != path[i].index,
+			!segment.isIndex && segment.key != path[i].key:
+			return 0, false
+		}
+	}
+	return wildcards, true
+}
+
+type jsonPathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func (s jsonPathSegment) String() string {
+	switch {
+	case s.wildcard:
+		return "[*]"
+	case s.isIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	default:
+		return "." + s.key
+	}
+}
+
+func formatJSONPath(path []jsonPathSegment) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, segment := range path {
+		b.WriteString(segment.String())
+	}
+	return b.String()
+}
+
+// parseJSONPath reads the JSONPath subset used by Pact matching rules and
+// JSON comparison options: $.a.b, $.a[0], $.a[*], $.a.* and $['a b'].
+func parseJSONPath(s string) ([]jsonPathSegment, bool) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, false
+	}
+	s = s[1:]
+
+	var path []jsonPathSegment
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return nil, false
+			}
+			name := s[:end]
+			path = append(path, jsonPathSegment{key: name, wildcard: name == "*"})
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, false
+			}
+			inner := s[1:end]
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				path = append(path, jsonPathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				path = append(path, jsonPathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, false
+				}
+				path = append(path, jsonPathSegment{index: index, isIndex: true})
+			}
+		default:
+			return nil, false
+		}
+	}
+	return path, true
+}
+
+func appendJSONPath(path []jsonPathSegment, segment jsonPathSegment) []jsonPathSegment {
+	return append(append([]jsonPathSegment(nil), path...), segment)
+}
+
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func jsonString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
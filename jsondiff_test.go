@@ -0,0 +1,79 @@
+package aduket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONDiff(t *testing.T) {
+	tests := []struct {
+		expected    string
+		actual      string
+		options     []JSONCompareOption
+		differences []string
+	}{
+		{
+			expected: `{"a": 1, "b": [1, 2]}`,
+			actual:   "{\n  \"b\": [1.0, 2e0],\n  \"a\": 1\n}",
+		},
+		{
+			expected: `{"name": "kalt", "address": {"city": "Istanbul"}, "age": 30}`,
+			actual:   `{"name": "kalt2", "address": {}, "age": "30", "admin": true}`,
+			differences: []string{
+				`$.address.city: missing, expected "Istanbul"`,
+				`$.admin: unexpected true`,
+				`$.age: expected number 30, got string "30"`,
+				`$.name: expected "kalt", got "kalt2"`,
+			},
+		},
+		{
+			expected: `[{"id": 1}, {"id": 2}]`,
+			actual:   `[{"id": 2}, {"id": 1}, {"id": 3}]`,
+			differences: []string{
+				`$[0].id: expected 1, got 2`,
+				`$[1].id: expected 2, got 1`,
+				`$[2]: unexpected {"id":3}`,
+			},
+		},
+		{
+			expected:    `[{"id": 1}, {"id": 2}, {"id": 2}]`,
+			actual:      `[{"id": 2}, {"id": 1}, {"id": 3}]`,
+			options:     []JSONCompareOption{UnorderedArrays()},
+			differences: []string{`$: missing element {"id":2}`, `$: unexpected element {"id":3}`},
+		},
+		{
+			expected:    `{"ordered": [1, 2], "tags": ["a", "b"]}`,
+			actual:      `{"ordered": [2, 1], "tags": ["b", "a"]}`,
+			options:     []JSONCompareOption{UnorderedArrays("tags")},
+			differences: []string{`$.ordered[0]: expected 1, got 2`, `$.ordered[1]: expected 2, got 1`},
+		},
+		{
+			expected: `{"items": [{"id": "a", "name": "x"}, {"id": "b", "name": "y"}], "createdAt": "now"}`,
+			actual:   `{"items": [{"id": "c", "name": "x"}, {"name": "y"}]}`,
+			options:  []JSONCompareOption{IgnoreFields("items[*].id", "$.createdAt")},
+		},
+		{
+			expected:    `{"total": 12345678901234567890}`,
+			actual:      `{"total": 12345678901234567891}`,
+			differences: []string{`$.total: expected 12345678901234567890, got 12345678901234567891`},
+		},
+	}
+
+	for _, test := range tests {
+		differences, err := jsonDiff([]byte(test.expected), []byte(test.actual), test.options...)
+		assert.Nil(t, err)
+		assert.Equal(t, test.differences, differences, test.expected)
+	}
+}
+
+func TestJSONDiffErrors(t *testing.T) {
+	_, err := jsonDiff([]byte(`{}`), []byte(`{} {}`))
+	assert.EqualError(t, err, "invalid JSON body: unexpected data after the JSON value")
+
+	_, err = jsonDiff([]byte(`{}`), nil)
+	assert.EqualError(t, err, "invalid JSON body: EOF")
+
+	_, err = jsonDiff([]byte(`{}`), []byte(`{}`), IgnoreFields("items["))
+	assert.EqualError(t, err, `invalid JSON path "items["`)
+}
@@ -0,0 +1,188 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	errMalformedJWT        = errors.New("malformed jwt")
+	errUnsupportedJWTAlg   = errors.New("unsupported jwt algorithm")
+	errInvalidJWTSignature = errors.New("invalid jwt signature")
+	errExpiredJWT          = errors.New("jwt is expired")
+)
+
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+type jwt struct {
+	header       map[string]interface{}
+	claims       map[string]interface{}
+	signingInput string
+	signature    []byte
+}
+
+func newJSONWebKey(kid string, key *rsa.PublicKey) JSONWebKey {
+	return JSONWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func (k JSONWebKey) publicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func signJWT(key *rsa.PrivateKey, kid string, claims map[string]interface{}) (string, error) {
+	header := map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": kid}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func parseJWT(token string) (jwt, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwt{}, errMalformedJWT
+	}
+
+	parsed := jwt{signingInput: parts[0] + "." + parts[1]}
+	if err := decodeJWTSegment(parts[0], &parsed.header); err != nil {
+		return jwt{}, err
+	}
+	if err := decodeJWTSegment(parts[1], &parsed.claims); err != nil {
+		return jwt{}, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwt{}, errMalformedJWT
+	}
+	parsed.signature = signature
+
+	return parsed, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errMalformedJWT
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errMalformedJWT
+	}
+	return nil
+}
+
+// verify checks the signature against an *rsa.PublicKey for RS256 or a
+// []byte shared secret for HS256.
+func (j jwt) verify(key interface{}) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if j.header["alg"] != "RS256" {
+			return errUnsupportedJWTAlg
+		}
+		digest := sha256.Sum256([]byte(j.signingInput))
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], j.signature); err != nil {
+			return errInvalidJWTSignature
+		}
+	case []byte:
+		if j.header["alg"] != "HS256" {
+			return errUnsupportedJWTAlg
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(j.signingInput))
+		if !hmac.Equal(mac.Sum(nil), j.signature) {
+			return errInvalidJWTSignature
+		}
+	default:
+		return errUnsupportedJWTAlg
+	}
+	return nil
+}
+
+func (j jwt) verifyWithKeySet(keySet JSONWebKeySet) error {
+	kid, _ := j.header["kid"].(string)
+	for _, k := range keySet.Keys {
+		if kid != "" && k.Kid != kid {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		if j.verify(key) == nil {
+			return nil
+		}
+	}
+	return errInvalidJWTSignature
+}
+
+func (j jwt) checkExpiry(now time.Time) error {
+	exp, ok := j.claims["exp"].(float64)
+	if ok && now.Unix() >= int64(exp) {
+		return errExpiredJWT
+	}
+	return nil
+}
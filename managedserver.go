@@ -0,0 +1,266 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+var (
+	ErrRouteExists   = errors.New("aduket: route already exists")
+	ErrRouteNotFound = errors.New("aduket: route not found")
+)
+
+// Server is a mock server whose routes can be changed while it is serving.
+// Every change swaps in a freshly built router; requests already in flight
+// finish on the router they started with.
+type Server struct {
+	httpServer *httptest.Server
+
+	mu              sync.RWMutex
+	handler         http.Handler
+	initialOptions  map[Route][]ResponseRuleOption
+	rules           map[Route]responseRule
+	requestRecorder map[Route]*RequestRecorder
+	unmatched       *RequestRecorder
+	redirects       *RedirectJournal
+	sharedRedirects bool
+	adminAPI        bool
+	cors            *CORSPolicy
+}
+
+// NewManagedServer is StartManagedServer panicking on errors.
+func NewManagedServer(routeResponseOptions map[Route][]ResponseRuleOption, serverOptions ...ServerOption) *Server {
+	server, err := StartManagedServer(routeResponseOptions, serverOptions...)
+	if err != nil {
+		panic(err)
+	}
+	return server
+}
+
+// StartManagedServer starts a Server serving routeResponseOptions. It
+// returns an error when the listener can't be opened.
+func StartManagedServer(routeResponseOptions map[Route][]ResponseRuleOption, serverOptions ...ServerOption) (*Server, error) {
+	server := &Server{
+		initialOptions:  make(map[Route][]ResponseRuleOption, len(routeResponseOptions)),
+		requestRecorder: make(map[Route]*RequestRecorder),
+		unmatched:       NewRequestRecorder(),
+		redirects:       NewRedirectJournal(),
+	}
+	config := createServerConfig(serverOptions)
+	server.adminAPI, server.cors = config.adminAPI, config.cors
+	if config.redirects != nil {
+		server.redirects, server.sharedRedirects = config.redirects, true
+	}
+	for route, options := range routeResponseOptions {
+		server.initialOptions[route] = options
+	}
+	server.rules = createRouteResponseRules(server.initialOptions)
+	server.rebuild()
+
+	httpServer, err := startConfiguredServer(server, config)
+	if err != nil {
+		return nil, err
+	}
+	server.httpServer = httpServer
+	return server, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	handler := s.handler
+	s.mu.RUnlock()
+
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+// Client returns an *http.Client configured for the server, trusting its
+// certificate when serving TLS.
+func (s *Server) Client() *http.Client {
+	return s.httpServer.Client()
+}
+
+func (s *Server) Close() {
+	s.httpServer.Close()
+	if !s.sharedRedirects {
+		s.redirects.clear()
+	}
+}
+
+// Recorder returns the recorder of route, or nil if the route is not served.
+func (s *Server) Recorder(route Route) *RequestRecorder {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.rules[route]; !ok {
+		return nil
+	}
+	return s.requestRecorder[route]
+}
+
+// Unmatched records the requests that matched no route.
+func (s *Server) Unmatched() *RequestRecorder {
+	return s.unmatched
+}
+
+// Journal returns every request the server received, matched or not, in the
+// order they arrived.
+func (s *Server) Journal() []*RecordedRequest {
+	s.mu.RLock()
+	recorders := []*RequestRecorder{s.unmatched}
+	for _, recorder := range s.requestRecorder {
+		recorders = append(recorders, recorder)
+	}
+	s.mu.RUnlock()
+
+	var journal []*RecordedRequest
+	for _, recorder := range recorders {
+		recorder.mu.Lock()
+		journal = append(journal, recorder.Requests...)
+		recorder.mu.Unlock()
+	}
+	sort.SliceStable(journal, func(i, j int) bool {
+		return journal[i].ReceivedAt.Before(journal[j].ReceivedAt)
+	})
+	return journal
+}
+
+// Routes returns the routes currently served.
+func (s *Server) Routes() []Route {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	routes := make([]Route, 0, len(s.rules))
+	for route := range s.rules {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func (s *Server) AddRoute(route Route, responseRuleOptions ...ResponseRuleOption) (*RequestRecorder, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rules[route]; ok {
+		return nil, ErrRouteExists
+	}
+	s.rules[route] = createResponseRule(responseRuleOptions)
+	s.rebuild()
+
+	return s.requestRecorder[route], nil
+}
+
+// RemoveRoute stops serving route. Its recorder keeps what it recorded and is
+// reused if the route is added again.
+func (s *Server) RemoveRoute(route Route) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rules[route]; !ok {
+		return ErrRouteNotFound
+	}
+	delete(s.rules, route)
+	s.rebuild()
+
+	return nil
+}
+
+// ReplaceResponse changes how route responds, keeping its recorder.
+func (s *Server) ReplaceResponse(route Route, responseRuleOptions ...ResponseRuleOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rules[route]; !ok {
+		return ErrRouteNotFound
+	}
+	s.rules[route] = createResponseRule(responseRuleOptions)
+	s.rebuild()
+
+	return nil
+}
+
+// SetRoutes replaces every route at once and makes them the routes Reset
+// restores. Recorders of routes that remain are kept.
+func (s *Server) SetRoutes(routeResponseOptions map[Route][]ResponseRuleOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.initialOptions = make(map[Route][]ResponseRuleOption, len(routeResponseOptions))
+	for route, options := range routeResponseOptions {
+		s.initialOptions[route] = options
+	}
+	s.rules = createRouteResponseRules(s.initialOptions)
+	s.rebuild()
+}
+
+// ClearRequests clears every recorder, keeping the routes as they are.
+func (s *Server) ClearRequests() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.clearRequests()
+}
+
+// Reset restores the routes the server started with and clears every
+// recorder. State kept by options themselves, such as a ResourceCollection,
+// is left alone.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clearRequests()
+	s.rules = createRouteResponseRules(s.initialOptions)
+	s.rebuild()
+}
+
+func (s *Server) clearRequests() {
+	for _, recorder := range s.requestRecorder {
+		recorder.Reset()
+	}
+	s.unmatched.Reset()
+	if !s.sharedRedirects {
+		s.redirects.clear()
+	}
+}
+
+// rebuild must be called with s.mu held.
+func (s *Server) rebuild() {
+	s.handler, _ = newRouteHandler(withDefaultCORS(s.rules, s.cors), s.requestRecorder, s.redirects, func(e *echo.Echo) {
+		if s.adminAPI {
+			registerAdminRoutes(e, s)
+		}
+		e.HTTPErrorHandler = func(err error, ctx echo.Context) {
+			if err == echo.ErrNotFound || err == echo.ErrMethodNotAllowed {
+				if bindErr := ctx.Bind(s.unmatched); bindErr == nil {
+					s.unmatched.markRequestReceived()
+					s.unmatched.setResponseStatus(ctx, err.(*echo.HTTPError).Code)
+				}
+			}
+			e.DefaultHTTPErrorHandler(err, ctx)
+		}
+	})
+}
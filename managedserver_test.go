@@ -0,0 +1,146 @@
+package aduket
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagedServerAddRoute(t *testing.T) {
+	userRoute := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	bookRoute := Route{HttpMethod: http.MethodGet, Path: "/book"}
+
+	server := NewManagedServer(map[Route][]ResponseRuleOption{userRoute: {StringBody("user")}})
+	defer server.Close()
+
+	response, err := http.Get(server.URL() + "/book")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	bookRecorder, err := server.AddRoute(bookRoute, StatusCode(http.StatusCreated))
+	assert.Nil(t, err)
+	assert.Equal(t, bookRecorder, server.Recorder(bookRoute))
+
+	response, err = http.Get(server.URL() + "/book")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Len(t, bookRecorder.Requests, 1)
+
+	_, err = server.AddRoute(userRoute)
+	assert.Equal(t, ErrRouteExists, err)
+	assert.ElementsMatch(t, []Route{userRoute, bookRoute}, server.Routes())
+}
+
+func TestManagedServerRemoveRoute(t *testing.T) {
+	route := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	server := NewManagedServer(map[Route][]ResponseRuleOption{route: {}})
+	defer server.Close()
+
+	requestRecorder := server.Recorder(route)
+	_, err := http.Get(server.URL() + "/user")
+	assert.Nil(t, err)
+
+	assert.Nil(t, server.RemoveRoute(route))
+	assert.Equal(t, ErrRouteNotFound, server.RemoveRoute(route))
+	assert.Nil(t, server.Recorder(route))
+
+	response, err := http.Get(server.URL() + "/user")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Len(t, requestRecorder.Requests, 1)
+}
+
+func TestManagedServerReplaceResponse(t *testing.T) {
+	route := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	server := NewManagedServer(map[Route][]ResponseRuleOption{route: {StringBody("before")}})
+	defer server.Close()
+
+	requestRecorder := server.Recorder(route)
+	_, err := http.Get(server.URL() + "/user")
+	assert.Nil(t, err)
+
+	assert.Nil(t, server.ReplaceResponse(route, StatusCode(http.StatusAccepted), StringBody("after")))
+
+	response, err := http.Get(server.URL() + "/user")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, http.StatusAccepted, response.StatusCode)
+	assert.Equal(t, "after", string(body))
+	assert.Len(t, requestRecorder.Requests, 2)
+
+	missing := Route{HttpMethod: http.MethodGet, Path: "/missing"}
+	assert.Equal(t, ErrRouteNotFound, server.ReplaceResponse(missing))
+}
+
+func TestManagedServerReset(t *testing.T) {
+	route := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	addedRoute := Route{HttpMethod: http.MethodGet, Path: "/book"}
+	server := NewManagedServer(map[Route][]ResponseRuleOption{route: {StringBody("user")}})
+	defer server.Close()
+
+	requestRecorder := server.Recorder(route)
+	_, err := server.AddRoute(addedRoute)
+	assert.Nil(t, err)
+	assert.Nil(t, server.ReplaceResponse(route, StatusCode(http.StatusTeapot)))
+	_, err = http.Get(server.URL() + "/user")
+	assert.Nil(t, err)
+
+	server.Reset()
+
+	assert.Empty(t, requestRecorder.Requests)
+	assert.Equal(t, requestRecorder, server.Recorder(route))
+	assert.Nil(t, server.Recorder(addedRoute))
+
+	response, err := http.Get(server.URL() + "/user")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Len(t, requestRecorder.Requests, 1)
+}
+
+func TestManagedServerChangesWhileServing(t *testing.T) {
+	route := Route{HttpMethod: http.MethodGet, Path: "/user"}
+	server := NewManagedServer(map[Route][]ResponseRuleOption{route: {}})
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				response, err := http.Get(server.URL() + "/user")
+				if assert.Nil(t, err) {
+					response.Body.Close()
+				}
+			}
+		}()
+	}
+
+	extraRoute := Route{HttpMethod: http.MethodPost, Path: "/user"}
+	for i := 0; i < 25; i++ {
+		_, err := server.AddRoute(extraRoute)
+		assert.Nil(t, err)
+		assert.Nil(t, server.ReplaceResponse(route, StatusCode(http.StatusOK)))
+		assert.Nil(t, server.RemoveRoute(extraRoute))
+	}
+	wg.Wait()
+
+	assert.Len(t, server.Recorder(route).Requests, 100)
+}
+
+func TestStartManagedServerReturnsListenError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	server, err := StartManagedServer(map[Route][]ResponseRuleOption{}, ListenAddress(listener.Addr().String()))
+
+	assert.Nil(t, server)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "aduket: failed to listen on tcp")
+	}
+}
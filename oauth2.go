@@ -0,0 +1,335 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	OAuth2TokenPath     = "/token"
+	OAuth2JWKSPath      = "/jwks"
+	OAuth2DiscoveryPath = "/.well-known/openid-configuration"
+)
+
+type OAuth2Provider struct {
+	TokenRequestRecorder *RequestRecorder
+
+	issuer  string
+	keyID   string
+	key     *rsa.PrivateKey
+	clients map[string]string
+	users   map[string]string
+	claims  map[string]interface{}
+	expiry  time.Duration
+
+	mu            sync.Mutex
+	refreshTokens map[string]oauth2Grant
+}
+
+type oauth2Grant struct {
+	clientID string
+	subject  string
+	scope    string
+}
+
+type OAuth2Option func(*OAuth2Provider)
+
+func OAuth2Client(clientID, clientSecret string) OAuth2Option {
+	return func(p *OAuth2Provider) {
+		p.clients[clientID] = clientSecret
+	}
+}
+
+func OAuth2User(username, password string) OAuth2Option {
+	return func(p *OAuth2Provider) {
+		p.users[username] = password
+	}
+}
+
+func OAuth2Claims(claims map[string]interface{}) OAuth2Option {
+	return func(p *OAuth2Provider) {
+		for name, value := range claims {
+			p.claims[name] = value
+		}
+	}
+}
+
+func OAuth2TokenExpiry(expiry time.Duration) OAuth2Option {
+	return func(p *OAuth2Provider) {
+		p.expiry = expiry
+	}
+}
+
+func NewOAuth2Server(options ...OAuth2Option) (*httptest.Server, *OAuth2Provider) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	provider := &OAuth2Provider{
+		TokenRequestRecorder: NewRequestRecorder(),
+		keyID:                randomHex(8),
+		key:                  key,
+		clients:              make(map[string]string),
+		users:                make(map[string]string),
+		claims:               make(map[string]interface{}),
+		expiry:               time.Hour,
+		refreshTokens:        make(map[string]oauth2Grant),
+	}
+	for _, option := range options {
+		option(provider)
+	}
+
+	e := createEcho()
+	e.POST(OAuth2TokenPath, provider.tokenHandler)
+	e.GET(OAuth2JWKSPath, provider.jwksHandler)
+	e.GET(OAuth2DiscoveryPath, provider.discoveryHandler)
+
+	server := startServer(e)
+	provider.issuer = server.URL
+
+	return server, provider
+}
+
+func (p *OAuth2Provider) Issuer() string {
+	return p.issuer
+}
+
+func (p *OAuth2Provider) KeySet() JSONWebKeySet {
+	return JSONWebKeySet{Keys: []JSONWebKey{newJSONWebKey(p.keyID, &p.key.PublicKey)}}
+}
+
+// Token issues an access token for subject without going through the token
+// endpoint, for tests that only need a valid bearer token.
+func (p *OAuth2Provider) Token(subject string, claims map[string]interface{}) string {
+	token, err := p.signToken(subject, "", claims)
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
+
+func (p *OAuth2Provider) validate(token string) error {
+	parsed, err := parseJWT(token)
+	if err != nil {
+		return err
+	}
+	if err := parsed.verify(&p.key.PublicKey); err != nil {
+		return err
+	}
+	if parsed.claims["iss"] != p.issuer {
+		return errInvalidJWTSignature
+	}
+	return parsed.checkExpiry(time.Now())
+}
+
+func (p *OAuth2Provider) signToken(subject, audience string, extraClaims map[string]interface{}) (string, error) {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": p.issuer,
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(p.expiry).Unix(),
+		"jti": randomHex(8),
+	}
+	if audience != "" {
+		claims["aud"] = audience
+	}
+	for name, value := range p.claims {
+		claims[name] = value
+	}
+	for name, value := range extraClaims {
+		claims[name] = value
+	}
+
+	return signJWT(p.key, p.keyID, claims)
+}
+
+func (p *OAuth2Provider) tokenHandler(ctx echo.Context) error {
+	if err := ctx.Bind(p.TokenRequestRecorder); err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return p.tokenError(ctx, http.StatusBadRequest, "invalid_request")
+	}
+
+	clientID, clientSecret, ok := ctx.Request().BasicAuth()
+	if !ok {
+		clientID, clientSecret = form.Get("client_id"), form.Get("client_secret")
+	}
+	if !p.isClientValid(clientID, clientSecret) {
+		ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, fmt.Sprintf(`Basic realm="%s"`, authRealm))
+		return p.tokenError(ctx, http.StatusUnauthorized, "invalid_client")
+	}
+
+	grant := oauth2Grant{clientID: clientID, scope: form.Get("scope")}
+	issueRefreshToken := true
+
+	switch form.Get("grant_type") {
+	case "client_credentials":
+		grant.subject = clientID
+		issueRefreshToken = false
+	case "password":
+		username := form.Get("username")
+		if !p.isUserValid(username, form.Get("password")) {
+			return p.tokenError(ctx, http.StatusBadRequest, "invalid_grant")
+		}
+		grant.subject = username
+	case "refresh_token":
+		previous, ok := p.takeRefreshToken(form.Get("refresh_token"))
+		if !ok || previous.clientID != clientID {
+			return p.tokenError(ctx, http.StatusBadRequest, "invalid_grant")
+		}
+		grant.subject = previous.subject
+		if grant.scope == "" {
+			grant.scope = previous.scope
+		}
+	case "":
+		return p.tokenError(ctx, http.StatusBadRequest, "invalid_request")
+	default:
+		return p.tokenError(ctx, http.StatusBadRequest, "unsupported_grant_type")
+	}
+
+	return p.issueTokens(ctx, grant, issueRefreshToken)
+}
+
+func (p *OAuth2Provider) issueTokens(ctx echo.Context, grant oauth2Grant, issueRefreshToken bool) error {
+	var scopeClaims map[string]interface{}
+	if grant.scope != "" {
+		scopeClaims = map[string]interface{}{"scope": grant.scope}
+	}
+
+	accessToken, err := p.signToken(grant.subject, grant.clientID, scopeClaims)
+	if err != nil {
+		return err
+	}
+
+	response := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(p.expiry.Seconds()),
+	}
+	if grant.scope != "" {
+		response["scope"] = grant.scope
+	}
+	if issueRefreshToken {
+		refreshToken := randomHex(16)
+		p.mu.Lock()
+		p.refreshTokens[refreshToken] = grant
+		p.mu.Unlock()
+		response["refresh_token"] = refreshToken
+	}
+	if containsScope(grant.scope, "openid") {
+		idToken, err := p.signToken(grant.subject, grant.clientID, nil)
+		if err != nil {
+			return err
+		}
+		response["id_token"] = idToken
+	}
+
+	p.TokenRequestRecorder.setResponseStatus(ctx, http.StatusOK)
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+	return ctx.JSON(http.StatusOK, response)
+}
+
+func (p *OAuth2Provider) tokenError(ctx echo.Context, statusCode int, code string) error {
+	p.TokenRequestRecorder.setResponseStatus(ctx, statusCode)
+	return ctx.JSON(statusCode, map[string]string{"error": code})
+}
+
+func (p *OAuth2Provider) jwksHandler(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, p.KeySet())
+}
+
+func (p *OAuth2Provider) discoveryHandler(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"token_endpoint":                        p.issuer + OAuth2TokenPath,
+		"jwks_uri":                              p.issuer + OAuth2JWKSPath,
+		"grant_types_supported":                 []string{"client_credentials", "password", "refresh_token"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"response_types_supported":              []string{"token"},
+		"subject_types_supported":               []string{"public"},
+	})
+}
+
+func (p *OAuth2Provider) isClientValid(clientID, clientSecret string) bool {
+	if len(p.clients) == 0 {
+		return true
+	}
+	secret, ok := p.clients[clientID]
+	return ok && secureEqual(secret, clientSecret)
+}
+
+func (p *OAuth2Provider) isUserValid(username, password string) bool {
+	if len(p.users) == 0 {
+		return username != ""
+	}
+	expected, ok := p.users[username]
+	return ok && secureEqual(expected, password)
+}
+
+func (p *OAuth2Provider) takeRefreshToken(refreshToken string) (oauth2Grant, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	grant, ok := p.refreshTokens[refreshToken]
+	delete(p.refreshTokens, refreshToken)
+	return grant, ok
+}
+
+func containsScope(scope, name string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+type oauth2Authenticator struct {
+	provider *OAuth2Provider
+}
+
+func (o oauth2Authenticator) authenticate(request *http.Request) bool {
+	token, ok := bearerToken(request)
+	return ok && o.provider.validate(token) == nil
+}
+
+func (o oauth2Authenticator) challenge(request *http.Request) string {
+	return bearerAuthenticator{}.challenge(request)
+}
@@ -0,0 +1,156 @@
+package aduket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	Error        string `json:"error"`
+}
+
+func TestOAuth2ClientCredentialsGrant(t *testing.T) {
+	server, provider := NewOAuth2Server(
+		OAuth2Client("cart", "s3cret"),
+		OAuth2Claims(map[string]interface{}{"tenant": "streetbyters"}),
+		OAuth2TokenExpiry(time.Minute),
+	)
+	defer server.Close()
+
+	request := newFormRequest(server.URL+OAuth2TokenPath, url.Values{"grant_type": {"client_credentials"}})
+	request.SetBasicAuth("cart", "s3cret")
+	status, token := requestToken(t, request)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.Equal(t, 60, token.ExpiresIn)
+	assert.Empty(t, token.RefreshToken)
+	assert.Nil(t, provider.validate(token.AccessToken))
+
+	parsed, err := parseJWT(token.AccessToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "cart", parsed.claims["sub"])
+	assert.Equal(t, "streetbyters", parsed.claims["tenant"])
+	assert.Equal(t, server.URL, parsed.claims["iss"])
+
+	provider.TokenRequestRecorder.AssertFormParamEqual(t, "grant_type", []string{"client_credentials"})
+
+	request = newFormRequest(server.URL+OAuth2TokenPath, url.Values{"grant_type": {"client_credentials"}})
+	request.SetBasicAuth("cart", "wrong")
+	status, token = requestToken(t, request)
+
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "invalid_client", token.Error)
+	assert.Len(t, provider.TokenRequestRecorder.Requests, 2)
+}
+
+func TestOAuth2PasswordAndRefreshTokenGrant(t *testing.T) {
+	server, provider := NewOAuth2Server(OAuth2User("ken", "hadouken"))
+	defer server.Close()
+
+	status, token := requestToken(t, newFormRequest(server.URL+OAuth2TokenPath, url.Values{
+		"grant_type": {"password"},
+		"username":   {"ken"},
+		"password":   {"hadouken"},
+		"scope":      {"openid profile"},
+	}))
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, token.RefreshToken)
+	assert.NotEmpty(t, token.IDToken)
+
+	status, refreshed := requestToken(t, newFormRequest(server.URL+OAuth2TokenPath, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	}))
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEqual(t, token.RefreshToken, refreshed.RefreshToken)
+	assert.Nil(t, provider.validate(refreshed.AccessToken))
+
+	status, reused := requestToken(t, newFormRequest(server.URL+OAuth2TokenPath, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	}))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", reused.Error)
+
+	status, denied := requestToken(t, newFormRequest(server.URL+OAuth2TokenPath, url.Values{
+		"grant_type": {"password"},
+		"username":   {"ken"},
+		"password":   {"shoryuken"},
+	}))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", denied.Error)
+}
+
+func TestOAuth2DiscoveryAndJWKS(t *testing.T) {
+	server, provider := NewOAuth2Server()
+	defer server.Close()
+
+	response, err := http.Get(server.URL + OAuth2DiscoveryPath)
+	assert.Nil(t, err)
+	discovery := map[string]interface{}{}
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&discovery))
+	assert.Equal(t, server.URL, discovery["issuer"])
+	assert.Equal(t, server.URL+OAuth2JWKSPath, discovery["jwks_uri"])
+
+	response, err = http.Get(server.URL + OAuth2JWKSPath)
+	assert.Nil(t, err)
+	keySet := JSONWebKeySet{}
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&keySet))
+
+	parsed, err := parseJWT(provider.Token("42", nil))
+	assert.Nil(t, err)
+	assert.Nil(t, parsed.verifyWithKeySet(keySet))
+}
+
+func TestRequireOAuth2(t *testing.T) {
+	oauth2Server, provider := NewOAuth2Server(OAuth2TokenExpiry(time.Minute))
+	defer oauth2Server.Close()
+
+	server, _ := NewServer(http.MethodGet, "/user", RequireOAuth2(provider), StringBody("Hello"))
+	defer server.Close()
+
+	tests := []struct {
+		token          string
+		expectedStatus int
+	}{
+		{provider.Token("42", nil), http.StatusOK},
+		{provider.Token("42", map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized},
+		{"not.a.jwt", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/user", http.NoBody)
+		request.Header.Set("Authorization", "Bearer "+test.token)
+
+		response, err := http.DefaultClient.Do(request)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expectedStatus, response.StatusCode)
+	}
+}
+
+func newFormRequest(url string, form url.Values) *http.Request {
+	request, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return request
+}
+
+func requestToken(t *testing.T, request *http.Request) (int, tokenResponse) {
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+
+	token := tokenResponse{}
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&token))
+	return response.StatusCode, token
+}
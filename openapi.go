@@ -0,0 +1,507 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo"
+)
+
+type OpenAPIOption func(*openAPIConfig)
+
+type openAPIConfig struct {
+	basePath         *string
+	serverOptions    []ServerOption
+	operationOptions map[string][]ResponseRuleOption
+}
+
+// OpenAPIBasePath mounts the operations under basePath instead of the path of
+// the spec's first server URL.
+func OpenAPIBasePath(basePath string) OpenAPIOption {
+	return func(c *openAPIConfig) {
+		c.basePath = &basePath
+	}
+}
+
+func OpenAPIServerOptions(serverOptions ...ServerOption) OpenAPIOption {
+	return func(c *openAPIConfig) {
+		c.serverOptions = append(c.serverOptions, serverOptions...)
+	}
+}
+
+// OperationOptions adds options such as RequireBearer or ExpectCalled to an
+// operation. The response itself keeps coming from the spec.
+func OperationOptions(operationID string, options ...ResponseRuleOption) OpenAPIOption {
+	return func(c *openAPIConfig) {
+		c.operationOptions[operationID] = append(c.operationOptions[operationID], options...)
+	}
+}
+
+// NewServerFromOpenAPI serves every operation of an OpenAPI 3 spec, answering
+// with the spec's examples or bodies generated from its schemas. Clients pick
+// another documented response with a "Prefer: code=404" header and a named
+// example with "Prefer: example=name". Recorders are keyed by operationId, or
+// by "METHOD /path/{template}" for operations without one.
+func NewServerFromOpenAPI(specPath string, options ...OpenAPIOption) (*httptest.Server, map[string]*RequestRecorder) {
+	config := &openAPIConfig{operationOptions: make(map[string][]ResponseRuleOption)}
+	for _, option := range options {
+		option(config)
+	}
+
+	spec, err := loadOpenAPISpec(specPath)
+	if err != nil {
+		panic(err)
+	}
+
+	basePath := ""
+	if config.basePath != nil {
+		basePath = *config.basePath
+	} else if len(spec.Servers) != 0 {
+		if path, err := spec.Servers[0].BasePath(); err == nil {
+			basePath = path
+		}
+	}
+	basePath = strings.TrimSuffix(basePath, "/")
+
+	routeResponseOptions := make(map[Route][]ResponseRuleOption)
+	routeOperations := make(map[Route]string)
+	for _, operation := range openAPIOperations(spec) {
+		route := Route{HttpMethod: operation.method, Path: basePath + echoPath(operation.path)}
+		routeOperations[route] = operation.id
+
+		routeOptions := []ResponseRuleOption{openAPIResponse(operation)}
+		routeResponseOptions[route] = append(routeOptions, config.operationOptions[operation.id]...)
+	}
+
+	server, routeRecorders := NewMultiRouteServer(routeResponseOptions, config.serverOptions...)
+
+	requestRecorder := make(map[string]*RequestRecorder, len(routeRecorders))
+	for route, recorder := range routeRecorders {
+		requestRecorder[routeOperations[route]] = recorder
+	}
+	return server, requestRecorder
+}
+
+func loadOpenAPISpec(specPath string) (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	spec, err := loader.LoadFromFile(specPath)
+	if err != nil {
+		return nil, fmt.Errorf("aduket: failed to load OpenAPI spec %s: %v", specPath, err)
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("aduket: invalid OpenAPI spec %s: %v", specPath, err)
+	}
+	return spec, nil
+}
+
+type openAPIOperation struct {
+	id        string
+	method    string
+	path      string
+	spec      *openapi3.T
+	pathItem  *openapi3.PathItem
+	operation *openapi3.Operation
+}
+
+func openAPIOperations(spec *openapi3.T) []*openAPIOperation {
+	var operations []*openAPIOperation
+	for path, pathItem := range spec.Paths.Map() {
+		for method, operation := range pathItem.Operations() {
+			id := operation.OperationID
+			if id == "" {
+				id = method + " " + path
+			}
+			operations = append(operations, &openAPIOperation{
+				id:        id,
+				method:    method,
+				path:      path,
+				spec:      spec,
+				pathItem:  pathItem,
+				operation: operation,
+			})
+		}
+	}
+	return operations
+}
+
+var pathTemplateParameter = regexp.MustCompile(`\{([^}/]+)\}`)
+
+// echoPath turns /users/{id} into /users/:id.
+func echoPath(path string) string {
+	return pathTemplateParameter.ReplaceAllString(path, ":$1")
+}
+
+func openAPIResponse(operation *openAPIOperation) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.responder = operation
+		r.validator = operation
+	}
+}
+
+// validate checks parameters, content type and body of a request against the
+// operation. Security requirements are left to options such as RequireBearer.
+func (o *openAPIOperation) validate(request *http.Request, params map[string]string) []string {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	validated := request.Clone(request.Context())
+	validated.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    validated,
+		PathParams: params,
+		Route: &routers.Route{
+			Spec:      o.spec,
+			Path:      o.path,
+			PathItem:  o.pathItem,
+			Method:    o.method,
+			Operation: o.operation,
+		},
+		Options: &openapi3filter.Options{
+			MultiError:          true,
+			SkipSettingDefaults: true,
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+
+	err = openapi3filter.ValidateRequest(request.Context(), input)
+	if err == nil {
+		return nil
+	}
+
+	var violations []string
+	if multiError, ok := err.(openapi3.MultiError); ok {
+		for _, e := range multiError {
+			violations = append(violations, e.Error())
+		}
+		return violations
+	}
+	return []string{err.Error()}
+}
+
+func (o *openAPIOperation) respond(ctx echo.Context, requestRecorder *RequestRecorder) (int, interface{}) {
+	preferences := parsePrefer(ctx.Request().Header.Get("Prefer"))
+	statusCode, response := o.selectResponse(preferences["code"])
+	if response == nil {
+		return statusCode, nil
+	}
+
+	for name, header := range response.Headers {
+		if header.Value == nil {
+			continue
+		}
+		if value, ok := parameterExample(&header.Value.Parameter); ok {
+			ctx.Response().Header().Set(name, fmt.Sprint(value))
+		}
+	}
+
+	contentType, mediaType := selectMediaType(response.Content, ctx.Request().Header.Get(echo.HeaderAccept))
+	if mediaType == nil {
+		return statusCode, nil
+	}
+
+	body, ok := mediaTypeExample(mediaType, preferences["example"])
+	if !ok {
+		return statusCode, nil
+	}
+	if text, isText := body.(string); isText && !isJSONMediaType(contentType) {
+		return statusCode, rawResponse{contentType: contentType, body: []byte(text)}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return http.StatusInternalServerError, nil
+	}
+	return statusCode, rawResponse{contentType: contentType, body: data}
+}
+
+// selectResponse returns the preferred response, or the first 2xx one. A
+// preferred code that is not documented gets the default response, if any.
+func (o *openAPIOperation) selectResponse(preferredCode string) (int, *openapi3.Response) {
+	responses := o.operation.Responses.Map()
+
+	if preferredCode != "" {
+		statusCode, err := strconv.Atoi(preferredCode)
+		if err == nil {
+			for code, response := range responses {
+				if responseCodeMatches(code, statusCode) && response.Value != nil {
+					return statusCode, response.Value
+				}
+			}
+			if response := o.operation.Responses.Default(); response != nil {
+				return statusCode, response.Value
+			}
+			return statusCode, nil
+		}
+	}
+
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") && responses[code].Value != nil {
+			return responseStatusCode(code), responses[code].Value
+		}
+	}
+	if response := o.operation.Responses.Default(); response != nil {
+		return http.StatusOK, response.Value
+	}
+	if len(codes) != 0 {
+		return responseStatusCode(codes[0]), responses[codes[0]].Value
+	}
+	return http.StatusOK, nil
+}
+
+// responseCodeMatches supports ranges such as 4XX.
+func responseCodeMatches(code string, statusCode int) bool {
+	if strings.HasSuffix(strings.ToUpper(code), "XX") {
+		return len(code) == 3 && code[0] == strconv.Itoa(statusCode)[0]
+	}
+	return code == strconv.Itoa(statusCode)
+}
+
+func responseStatusCode(code string) int {
+	if strings.HasSuffix(strings.ToUpper(code), "XX") {
+		code = code[:1] + "00"
+	}
+	statusCode, err := strconv.Atoi(code)
+	if err != nil {
+		return http.StatusOK
+	}
+	return statusCode
+}
+
+func parsePrefer(header string) map[string]string {
+	preferences := make(map[string]string)
+	for _, preference := range strings.FieldsFunc(header, func(r rune) bool { return r == ',' || r == ';' }) {
+		parts := strings.SplitN(strings.TrimSpace(preference), "=", 2)
+		if len(parts) == 2 {
+			preferences[strings.ToLower(parts[0])] = strings.Trim(parts[1], `"`)
+		}
+	}
+	return preferences
+}
+
+// selectMediaType picks the first content type the client accepts, preferring
+// JSON.
+func selectMediaType(content openapi3.Content, accept string) (string, *openapi3.MediaType) {
+	contentTypes := make([]string, 0, len(content))
+	for contentType := range content {
+		contentTypes = append(contentTypes, contentType)
+	}
+	sort.Slice(contentTypes, func(i, j int) bool {
+		if isJSONMediaType(contentTypes[i]) != isJSONMediaType(contentTypes[j]) {
+			return isJSONMediaType(contentTypes[i])
+		}
+		return contentTypes[i] < contentTypes[j]
+	})
+
+	for _, contentType := range contentTypes {
+		if acceptsMediaType(accept, contentType) {
+			return contentType, content[contentType]
+		}
+	}
+	if len(contentTypes) == 0 {
+		return "", nil
+	}
+	return contentTypes[0], content[contentTypes[0]]
+}
+
+func acceptsMediaType(accept, contentType string) bool {
+	if accept == "" {
+		return true
+	}
+	for _, accepted := range strings.Split(accept, ",") {
+		accepted, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		if accepted == "*/*" || accepted == contentType || strings.HasSuffix(accepted, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(accepted, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == echo.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+func mediaTypeExample(mediaType *openapi3.MediaType, exampleName string) (interface{}, bool) {
+	if exampleName != "" {
+		if example, ok := mediaType.Examples[exampleName]; ok && example.Value != nil {
+			return example.Value.Value, true
+		}
+	}
+	if mediaType.Example != nil {
+		return mediaType.Example, true
+	}
+	if len(mediaType.Examples) != 0 {
+		names := make([]string, 0, len(mediaType.Examples))
+		for name := range mediaType.Examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if example := mediaType.Examples[names[0]]; example.Value != nil {
+			return example.Value.Value, true
+		}
+	}
+	if mediaType.Schema != nil && mediaType.Schema.Value != nil {
+		return schemaExample(mediaType.Schema.Value, 0), true
+	}
+	return nil, false
+}
+
+func parameterExample(parameter *openapi3.Parameter) (interface{}, bool) {
+	if parameter.Example != nil {
+		return parameter.Example, true
+	}
+	if parameter.Schema != nil && parameter.Schema.Value != nil {
+		return schemaExample(parameter.Schema.Value, 0), true
+	}
+	return nil, false
+}
+
+const maxSchemaExampleDepth = 8
+
+// schemaExample generates a value valid for simple schemas, preferring the
+// schema's own example, default or first enum value.
+func schemaExample(schema *openapi3.Schema, depth int) interface{} {
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) != 0:
+		return schema.Enum[0]
+	case schema.Const != nil:
+		return schema.Const
+	case depth > maxSchemaExampleDepth:
+		return nil
+	}
+
+	if len(schema.AllOf) != 0 {
+		merged := make(map[string]interface{})
+		for _, part := range schema.AllOf {
+			if part.Value == nil {
+				continue
+			}
+			if object, ok := schemaExample(part.Value, depth+1).(map[string]interface{}); ok {
+				for name, value := range object {
+					merged[name] = value
+				}
+			}
+		}
+		return merged
+	}
+	for _, alternatives := range []openapi3.SchemaRefs{schema.OneOf, schema.AnyOf} {
+		if len(alternatives) != 0 && alternatives[0].Value != nil {
+			return schemaExample(alternatives[0].Value, depth+1)
+		}
+	}
+
+	switch {
+	case hasSchemaType(schema, openapi3.TypeObject) || schema.Type.IsEmpty() && len(schema.Properties) != 0:
+		object := make(map[string]interface{}, len(schema.Properties))
+		for name, property := range schema.Properties {
+			if property.Value != nil {
+				object[name] = schemaExample(property.Value, depth+1)
+			}
+		}
+		return object
+	case hasSchemaType(schema, openapi3.TypeArray):
+		if schema.Items == nil || schema.Items.Value == nil {
+			return []interface{}{}
+		}
+		return []interface{}{schemaExample(schema.Items.Value, depth+1)}
+	case hasSchemaType(schema, openapi3.TypeString):
+		return stringExample(schema)
+	case hasSchemaType(schema, openapi3.TypeInteger):
+		if schema.Min != nil {
+			return int64(*schema.Min)
+		}
+		return 0
+	case hasSchemaType(schema, openapi3.TypeNumber):
+		if schema.Min != nil {
+			return *schema.Min
+		}
+		return 0.0
+	case hasSchemaType(schema, openapi3.TypeBoolean):
+		return true
+	}
+	return nil
+}
+
+func hasSchemaType(schema *openapi3.Schema, schemaType string) bool {
+	for _, t := range schema.Type.Slice() {
+		if t == schemaType {
+			return true
+		}
+	}
+	return false
+}
+
+func stringExample(schema *openapi3.Schema) string {
+	switch schema.Format {
+	case "date":
+		return "2020-01-01"
+	case "date-time":
+		return "2020-01-01T00:00:00Z"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "uri", "url":
+		return "https://example.com"
+	case "ipv4":
+		return "127.0.0.1"
+	case "ipv6":
+		return "::1"
+	}
+
+	example := "string"
+	for uint64(len(example)) < schema.MinLength {
+		example += "string"
+	}
+	if schema.MaxLength != nil && uint64(len(example)) > *schema.MaxLength {
+		example = example[:*schema.MaxLength]
+	}
+	return example
+}
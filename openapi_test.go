@@ -0,0 +1,185 @@
+package aduket
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const openAPISpec = "testdata/openapi.yaml"
+
+func getWithHeader(t *testing.T, url, name, value string) (*http.Response, []byte) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.Nil(t, err)
+	if name != "" {
+		request.Header.Set(name, value)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	assert.Nil(t, err)
+	return response, body
+}
+
+func TestNewServerFromOpenAPIRoutes(t *testing.T) {
+	server, requestRecorder := NewServerFromOpenAPI(openAPISpec)
+	defer server.Close()
+
+	assert.Len(t, requestRecorder, 5)
+	for _, operation := range []string{"listUsers", "createUser", "getUser", "DELETE /users/{id}", "health"} {
+		assert.NotNil(t, requestRecorder[operation], operation)
+	}
+
+	response, _ := getWithHeader(t, server.URL+"/v1/users/7", "", "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "7", requestRecorder["getUser"].Params["id"])
+
+	request, _ := http.NewRequest(http.MethodDelete, server.URL+"/v1/users/7", nil)
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Len(t, requestRecorder["DELETE /users/{id}"].Requests, 1)
+}
+
+func TestNewServerFromOpenAPIExamples(t *testing.T) {
+	server, _ := NewServerFromOpenAPI(openAPISpec)
+	defer server.Close()
+
+	response, body := getWithHeader(t, server.URL+"/v1/users/1", "", "")
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"id": 2, "name": "root", "admin": true}`, string(body))
+
+	_, body = getWithHeader(t, server.URL+"/v1/users/1", "Prefer", "example=regular")
+	assert.JSONEq(t, `{"id": 1, "name": "kalt"}`, string(body))
+
+	response, err := http.Post(server.URL+"/v1/users", "application/json", strings.NewReader(`{"name": "kalt"}`))
+	assert.Nil(t, err)
+	body, _ = ioutil.ReadAll(response.Body)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.JSONEq(t, `{"id": 42, "name": "kalt"}`, string(body))
+
+	response, body = getWithHeader(t, server.URL+"/v1/health", "", "")
+	assert.Equal(t, "text/plain", response.Header.Get("Content-Type"))
+	assert.Equal(t, "ok", string(body))
+}
+
+func TestNewServerFromOpenAPIGeneratesBodiesFromSchemas(t *testing.T) {
+	server, _ := NewServerFromOpenAPI(openAPISpec)
+	defer server.Close()
+
+	response, body := getWithHeader(t, server.URL+"/v1/users", "", "")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "2", response.Header.Get("X-Total-Count"))
+
+	var users []map[string]interface{}
+	assert.Nil(t, json.Unmarshal(body, &users))
+	assert.Equal(t, []map[string]interface{}{{
+		"id":        float64(0),
+		"name":      "string",
+		"email":     "user@example.com",
+		"createdAt": "2020-01-01T00:00:00Z",
+	}}, users)
+}
+
+func TestNewServerFromOpenAPIPreferCode(t *testing.T) {
+	server, _ := NewServerFromOpenAPI(openAPISpec)
+	defer server.Close()
+
+	response, body := getWithHeader(t, server.URL+"/v1/users/1", "Prefer", "code=404")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.JSONEq(t, `{"title": "Not Found", "status": 404}`, string(body))
+
+	request, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/users", strings.NewReader(`{}`))
+	request.Header.Set("Prefer", "code=422")
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, response.StatusCode)
+	assert.Equal(t, "application/problem+json", response.Header.Get("Content-Type"))
+
+	response, body = getWithHeader(t, server.URL+"/v1/users/1", "Prefer", "code=500")
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Empty(t, body)
+}
+
+func TestNewServerFromOpenAPIOptions(t *testing.T) {
+	server, requestRecorder := NewServerFromOpenAPI(openAPISpec,
+		OpenAPIBasePath("/"),
+		OperationOptions("getUser", RequireBearer("secret")),
+	)
+	defer server.Close()
+
+	response, _ := getWithHeader(t, server.URL+"/users/1", "", "")
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+
+	response, _ = getWithHeader(t, server.URL+"/users/1", "Authorization", "Bearer secret")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Len(t, requestRecorder["getUser"].Requests, 2)
+}
+
+func TestNewServerFromOpenAPIInvalidSpec(t *testing.T) {
+	path := writeConfig(t, "broken.yaml", "openapi: 3.0.3\ninfo: {title: Broken}\npaths: {}\n")
+
+	assert.Panics(t, func() { NewServerFromOpenAPI(path) })
+	assert.Panics(t, func() { NewServerFromOpenAPI("testdata/missing.yaml") })
+}
+
+func TestOpenAPIRequestValidation(t *testing.T) {
+	server, requestRecorder := NewServerFromOpenAPI(openAPISpec)
+	defer server.Close()
+
+	_, err := http.Post(server.URL+"/v1/users", "application/json", strings.NewReader(`{"name": "kalt", "email": "kalt@example.com"}`))
+	assert.Nil(t, err)
+	_, _ = getWithHeader(t, server.URL+"/v1/users?limit=10", "", "")
+	_, _ = getWithHeader(t, server.URL+"/v1/users/3", "", "")
+
+	for _, recorder := range requestRecorder {
+		recorder.AssertValidAgainstSpec(t)
+	}
+
+	tests := []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+		operation   string
+		violation   string
+	}{
+		{method: http.MethodGet, path: "/v1/users?limit=ten", operation: "listUsers", violation: `parameter "limit" in query`},
+		{method: http.MethodGet, path: "/v1/users?limit=0", operation: "listUsers", violation: "number must be at least 1"},
+		{method: http.MethodGet, path: "/v1/users/abc", operation: "getUser", violation: `parameter "id" in path`},
+		{method: http.MethodPost, path: "/v1/users", contentType: "application/json", body: `{"email": "kalt@example.com"}`, operation: "createUser", violation: `property "name" is missing`},
+		{method: http.MethodPost, path: "/v1/users", contentType: "application/json", body: `{"name": "kalt", "role": "admin"}`, operation: "createUser", violation: `property "role" is unsupported`},
+		{method: http.MethodPost, path: "/v1/users", contentType: "text/plain", body: `kalt`, operation: "createUser", violation: `header Content-Type has unexpected value`},
+		{method: http.MethodPost, path: "/v1/users", operation: "createUser", violation: "value is required but missing"},
+	}
+
+	for _, test := range tests {
+		request, _ := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+		if test.contentType != "" {
+			request.Header.Set("Content-Type", test.contentType)
+		}
+		_, err := http.DefaultClient.Do(request)
+		assert.Nil(t, err)
+
+		requests := requestRecorder[test.operation].Requests
+		violations := strings.Join(requests[len(requests)-1].SpecViolations, "\n")
+		assert.Contains(t, violations, test.violation, test.path)
+	}
+}
+
+func TestAssertValidAgainstSpec(t *testing.T) {
+	server, requestRecorder := NewServerFromOpenAPI(openAPISpec)
+	defer server.Close()
+
+	_, _ = getWithHeader(t, server.URL+"/v1/users?limit=ten", "", "")
+
+	tester := &testing.T{}
+	assert.False(t, requestRecorder["listUsers"].AssertValidAgainstSpec(tester))
+	assert.True(t, tester.Failed())
+	assert.True(t, requestRecorder["getUser"].AssertValidAgainstSpec(t))
+}
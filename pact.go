@@ -0,0 +1,460 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type PactVersion string
+
+const (
+	PactV3 PactVersion = "3.0.0"
+	PactV4 PactVersion = "4.0"
+)
+
+// Pact is a consumer-driven contract. It is written and read in the Pact
+// specification given by Version.
+type Pact struct {
+	Consumer     string
+	Provider     string
+	Version      PactVersion
+	Interactions []PactInteraction
+}
+
+type PactInteraction struct {
+	Description   string
+	ProviderState string
+	Request       PactRequest
+	Response      PactResponse
+}
+
+// PactRequest and PactResponse hold JSON bodies decoded and other bodies as
+// strings. MatchingRules maps a category such as "path", "body" or "header"
+// to Pact V3 rules, e.g. {"body": {"$.id": {"matchers": [{"match": "type"}]}}}.
+type PactRequest struct {
+	Method        string
+	Path          string
+	Query         url.Values
+	Header        http.Header
+	Body          interface{}
+	MatchingRules map[string]interface{}
+}
+
+type PactResponse struct {
+	StatusCode    int
+	Header        http.Header
+	Body          interface{}
+	MatchingRules map[string]interface{}
+}
+
+// ProviderState names the provider state the route's interactions need when
+// written to a Pact.
+func ProviderState(state string) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.providerState = state
+	}
+}
+
+// PactOption configures Server.Pact.
+type PactOption func(map[string]bool)
+
+// PactRequestHeaders adds the request headers named to the contract. Only
+// Content-Type and Accept are written by default, so credentials such as
+// Authorization or Cookie and per request ids stay out of published pacts
+// unless asked for.
+func PactRequestHeaders(names ...string) PactOption {
+	return func(requestHeaders map[string]bool) {
+		for _, name := range names {
+			requestHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// Pact writes every request the routes received, with the response it got,
+// as an interaction. Path parameters become regex matchers and JSON response
+// bodies are matched by type, so providers may return other values.
+func (s *Server) Pact(consumer, provider string, version PactVersion, options ...PactOption) *Pact {
+	requestHeaders := map[string]bool{echo.HeaderContentType: true, echo.HeaderAccept: true}
+	for _, option := range options {
+		option(requestHeaders)
+	}
+
+	s.mu.RLock()
+	routes := make([]Route, 0, len(s.rules))
+	for route := range s.rules {
+		routes = append(routes, route)
+	}
+	sortRoutes(routes)
+
+	pact := &Pact{Consumer: consumer, Provider: provider, Version: version}
+	descriptions := make(map[string]int)
+	for _, route := range routes {
+		rule := s.rules[route]
+		recorder := s.requestRecorder[route]
+
+		recorder.mu.Lock()
+		requests := append([]*RecordedRequest(nil), recorder.Requests...)
+		recorder.mu.Unlock()
+
+		for _, request := range requests {
+			interaction := newPactInteraction(route, rule, request, requestHeaders)
+			descriptions[interaction.Description]++
+			if count := descriptions[interaction.Description]; count > 1 {
+				interaction.Description = fmt.Sprintf("%s #%d", interaction.Description, count)
+			}
+			pact.Interactions = append(pact.Interactions, interaction)
+		}
+	}
+	s.mu.RUnlock()
+
+	return pact
+}
+
+func newPactInteraction(route Route, rule responseRule, request *RecordedRequest, requestHeaders map[string]bool) PactInteraction {
+	interaction := PactInteraction{
+		Description:   fmt.Sprintf("%s %s", request.Method, request.Path),
+		ProviderState: rule.providerState,
+		Request: PactRequest{
+			Method: request.Method,
+			Path:   request.Path,
+			Header: http.Header{},
+			Body:   pactBody(request.Header.Get(echo.HeaderContentType), request.Body),
+		},
+		Response: PactResponse{
+			StatusCode: request.StatusCode,
+			Header:     http.Header{},
+			Body:       pactBody(request.ResponseHeader.Get(echo.HeaderContentType), request.ResponseBody),
+		},
+	}
+
+	if len(request.QueryParams) != 0 {
+		interaction.Request.Query = request.QueryParams
+	}
+	for name, values := range request.Header {
+		if requestHeaders[name] {
+			interaction.Request.Header[name] = values
+		}
+	}
+	if strings.ContainsAny(route.Path, ":*") {
+		interaction.Request.MatchingRules = map[string]interface{}{
+			"path": pactMatchers(map[string]interface{}{"match": "regex", "regex": "^" + routePathRegex(route.Path) + "$"}),
+		}
+	}
+
+	responseRules := make(map[string]interface{})
+	for name := range rule.header {
+		interaction.Response.Header[name] = request.ResponseHeader[name]
+	}
+	if contentType := request.ResponseHeader.Get(echo.HeaderContentType); contentType != "" {
+		interaction.Response.Header.Set(echo.HeaderContentType, contentType)
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			responseRules["header"] = map[string]interface{}{
+				echo.HeaderContentType: pactMatchers(map[string]interface{}{"match": "regex", "regex": "^" + regexp.QuoteMeta(mediaType)}),
+			}
+		}
+	}
+	if _, ok := interaction.Response.Body.(string); !ok && interaction.Response.Body != nil {
+		responseRules["body"] = map[string]interface{}{"$": pactMatchers(map[string]interface{}{"match": "type"})}
+	}
+	if len(responseRules) != 0 {
+		interaction.Response.MatchingRules = responseRules
+	}
+
+	return interaction
+}
+
+func pactMatchers(matchers ...interface{}) map[string]interface{} {
+	return map[string]interface{}{"matchers": matchers}
+}
+
+func pactBody(contentType string, body []byte) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	// Stubs built with JSONBody send no content type.
+	if contentType == "" || isJSONMediaType(contentType) {
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err == nil {
+			return value
+		}
+	}
+	return string(body)
+}
+
+// Save writes the Pact to dir as <consumer>-<provider>.json, the name Pact
+// brokers expect.
+func (p *Pact) Save(dir string) (string, error) {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, p.Consumer+"-"+p.Provider+".json")
+	return path, ioutil.WriteFile(path, data, 0644)
+}
+
+func LoadPact(path string) (*Pact, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pact := &Pact{}
+	if err := json.Unmarshal(data, pact); err != nil {
+		return nil, fmt.Errorf("aduket: invalid pact %s: %v", path, err)
+	}
+	return pact, nil
+}
+
+type pactDocument struct {
+	Consumer     pactParticipant   `json:"consumer"`
+	Provider     pactParticipant   `json:"provider"`
+	Interactions []pactInteraction `json:"interactions"`
+	Metadata     pactMetadata      `json:"metadata"`
+}
+
+type pactParticipant struct {
+	Name string `json:"name"`
+}
+
+type pactMetadata struct {
+	PactSpecification struct {
+		Version string `json:"version"`
+	} `json:"pactSpecification"`
+}
+
+type pactInteraction struct {
+	Type           string                 `json:"type,omitempty"`
+	Key            string                 `json:"key,omitempty"`
+	Description    string                 `json:"description"`
+	ProviderState  string                 `json:"providerState,omitempty"`
+	ProviderStates []pactProviderState    `json:"providerStates,omitempty"`
+	Request        map[string]interface{} `json:"request"`
+	Response       map[string]interface{} `json:"response"`
+}
+
+type pactProviderState struct {
+	Name string `json:"name"`
+}
+
+func (p *Pact) MarshalJSON() ([]byte, error) {
+	version := p.Version
+	if version == "" {
+		version = PactV3
+	}
+
+	document := pactDocument{
+		Consumer:     pactParticipant{Name: p.Consumer},
+		Provider:     pactParticipant{Name: p.Provider},
+		Interactions: make([]pactInteraction, 0, len(p.Interactions)),
+	}
+	document.Metadata.PactSpecification.Version = string(version)
+
+	for i, interaction := range p.Interactions {
+		encoded := pactInteraction{Description: interaction.Description}
+		if interaction.ProviderState != "" {
+			encoded.ProviderStates = []pactProviderState{{Name: interaction.ProviderState}}
+		}
+		if version == PactV4 {
+			encoded.Type = "Synchronous/HTTP"
+			encoded.Key = fmt.Sprintf("%d", i)
+		}
+
+		encoded.Request = map[string]interface{}{
+			"method": interaction.Request.Method,
+			"path":   interaction.Request.Path,
+		}
+		if len(interaction.Request.Query) != 0 {
+			encoded.Request["query"] = interaction.Request.Query
+		}
+		encodePactMessage(encoded.Request, version, interaction.Request.Header, interaction.Request.Body, interaction.Request.MatchingRules)
+
+		encoded.Response = map[string]interface{}{"status": interaction.Response.StatusCode}
+		encodePactMessage(encoded.Response, version, interaction.Response.Header, interaction.Response.Body, interaction.Response.MatchingRules)
+
+		document.Interactions = append(document.Interactions, encoded)
+	}
+
+	return json.Marshal(document)
+}
+
+func encodePactMessage(message map[string]interface{}, version PactVersion, header http.Header, body interface{}, matchingRules map[string]interface{}) {
+	if len(header) != 0 {
+		if version == PactV4 {
+			message["headers"] = header
+		} else {
+			flattened := make(map[string]string, len(header))
+			for name, values := range header {
+				flattened[name] = strings.Join(values, ", ")
+			}
+			message["headers"] = flattened
+		}
+	}
+	if body != nil {
+		if version == PactV4 {
+			content := map[string]interface{}{"content": body, "encoded": false}
+			if contentType := header.Get(echo.HeaderContentType); contentType != "" {
+				content["contentType"] = contentType
+			}
+			message["body"] = content
+		} else {
+			message["body"] = body
+		}
+	}
+	if len(matchingRules) != 0 {
+		message["matchingRules"] = matchingRules
+	}
+}
+
+func (p *Pact) UnmarshalJSON(data []byte) error {
+	var document struct {
+		Consumer     pactParticipant `json:"consumer"`
+		Provider     pactParticipant `json:"provider"`
+		Interactions []struct {
+			Description    string              `json:"description"`
+			ProviderState  string              `json:"providerState"`
+			ProviderStates []pactProviderState `json:"providerStates"`
+			Request        pactMessage         `json:"request"`
+			Response       pactMessage         `json:"response"`
+		} `json:"interactions"`
+		Metadata struct {
+			PactSpecification struct {
+				Version string `json:"version"`
+			} `json:"pactSpecification"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	p.Consumer, p.Provider = document.Consumer.Name, document.Provider.Name
+	p.Version = PactVersion(document.Metadata.PactSpecification.Version)
+	p.Interactions = nil
+	for _, interaction := range document.Interactions {
+		decoded := PactInteraction{Description: interaction.Description, ProviderState: interaction.ProviderState}
+		if len(interaction.ProviderStates) != 0 {
+			decoded.ProviderState = interaction.ProviderStates[0].Name
+		}
+
+		query, err := interaction.Request.query()
+		if err != nil {
+			return fmt.Errorf("interaction %q: %v", interaction.Description, err)
+		}
+		decoded.Request = PactRequest{
+			Method:        strings.ToUpper(interaction.Request.Method),
+			Path:          interaction.Request.Path,
+			Query:         query,
+			Header:        interaction.Request.header(),
+			Body:          interaction.Request.body(),
+			MatchingRules: interaction.Request.MatchingRules,
+		}
+		decoded.Response = PactResponse{
+			StatusCode:    interaction.Response.Status,
+			Header:        interaction.Response.header(),
+			Body:          interaction.Response.body(),
+			MatchingRules: interaction.Response.MatchingRules,
+		}
+		p.Interactions = append(p.Interactions, decoded)
+	}
+	return nil
+}
+
+// pactMessage reads requests and responses of every supported version: V2
+// query strings, V3 single header values and V4 body envelopes.
+type pactMessage struct {
+	Method        string                     `json:"method"`
+	Path          string                     `json:"path"`
+	Status        int                        `json:"status"`
+	Query         json.RawMessage            `json:"query"`
+	Headers       map[string]json.RawMessage `json:"headers"`
+	Body          json.RawMessage            `json:"body"`
+	MatchingRules map[string]interface{}     `json:"matchingRules"`
+}
+
+func (m pactMessage) query() (url.Values, error) {
+	if len(m.Query) == 0 {
+		return nil, nil
+	}
+
+	var raw string
+	if err := json.Unmarshal(m.Query, &raw); err == nil {
+		return url.ParseQuery(raw)
+	}
+	var values url.Values
+	if err := json.Unmarshal(m.Query, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (m pactMessage) header() http.Header {
+	header := http.Header{}
+	for name, raw := range m.Headers {
+		var values []string
+		if err := json.Unmarshal(raw, &values); err == nil {
+			header[http.CanonicalHeaderKey(name)] = values
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err == nil {
+			header.Set(name, value)
+		}
+	}
+	return header
+}
+
+func (m pactMessage) body() interface{} {
+	if len(m.Body) == 0 || string(m.Body) == "null" {
+		return nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(m.Body, &body); err != nil {
+		return nil
+	}
+
+	envelope, ok := body.(map[string]interface{})
+	if !ok {
+		return body
+	}
+	content, hasContent := envelope["content"]
+	if _, hasEncoded := envelope["encoded"]; hasContent && hasEncoded {
+		return content
+	}
+	return body
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
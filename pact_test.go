@@ -0,0 +1,239 @@
+package aduket
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPactConsumerServer(t *testing.T) *Server {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/users/:id"}: {
+			JSONBody(User{ID: 1, Name: "kalt"}),
+			Header(http.Header{"Content-Type": []string{"application/json"}}),
+			ProviderState("user 1 exists"),
+		},
+		{HttpMethod: http.MethodPost, Path: "/users"}: {
+			StatusCode(http.StatusCreated),
+			Header(http.Header{"Location": []string{"/users/2"}}),
+		},
+	})
+
+	_, err := http.Get(server.URL() + "/users/1?fields=name")
+	assert.Nil(t, err)
+	_, err = http.Get(server.URL() + "/users/1?fields=name")
+	assert.Nil(t, err)
+	_, err = http.Post(server.URL()+"/users", "application/json", strings.NewReader(`{"name":"kalt"}`))
+	assert.Nil(t, err)
+
+	return server
+}
+
+func TestServerPact(t *testing.T) {
+	server := newPactConsumerServer(t)
+	defer server.Close()
+
+	pact := server.Pact("web", "users", PactV3)
+	assert.Equal(t, "web", pact.Consumer)
+	assert.Equal(t, "users", pact.Provider)
+	assert.Len(t, pact.Interactions, 3)
+
+	get := pact.Interactions[1]
+	assert.Equal(t, "GET /users/1", get.Description)
+	assert.Equal(t, "GET /users/1 #2", pact.Interactions[2].Description)
+	assert.Equal(t, "user 1 exists", get.ProviderState)
+	assert.Equal(t, []string{"name"}, get.Request.Query["fields"])
+	assert.NotContains(t, get.Request.Header, "User-Agent")
+	assert.Equal(t, map[string]interface{}{
+		"path": map[string]interface{}{"matchers": []interface{}{map[string]interface{}{"match": "regex", "regex": "^/users/[^/]+$"}}},
+	}, get.Request.MatchingRules)
+	assert.Equal(t, http.StatusOK, get.Response.StatusCode)
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "name": "kalt"}, get.Response.Body)
+	assert.Contains(t, get.Response.MatchingRules, "body")
+
+	post := pact.Interactions[0]
+	assert.Equal(t, "POST /users", post.Description)
+	assert.Equal(t, map[string]interface{}{"name": "kalt"}, post.Request.Body)
+	assert.Nil(t, post.Request.MatchingRules)
+	assert.Equal(t, http.StatusCreated, post.Response.StatusCode)
+	assert.Equal(t, "/users/2", post.Response.Header.Get("Location"))
+	assert.Nil(t, post.Response.Body)
+}
+
+func TestServerPactRequestHeaders(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{{HttpMethod: http.MethodGet, Path: "/user"}: {StringBody("kalt")}})
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL()+"/user", nil)
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("Authorization", "Bearer secret")
+	request.Header.Set("Cookie", "session=secret")
+	request.Header.Set("X-Request-Id", "f81d")
+	request.Header.Set("X-Tenant", "acme")
+	_, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+
+	header := server.Pact("web", "users", PactV3).Interactions[0].Request.Header
+	assert.Equal(t, http.Header{"Accept": []string{"application/json"}}, header)
+
+	header = server.Pact("web", "users", PactV3, PactRequestHeaders("x-tenant")).Interactions[0].Request.Header
+	assert.Equal(t, http.Header{"Accept": []string{"application/json"}, "X-Tenant": []string{"acme"}}, header)
+}
+
+func TestPactJSON(t *testing.T) {
+	server := newPactConsumerServer(t)
+	defer server.Close()
+
+	for _, version := range []PactVersion{PactV3, PactV4} {
+		pact := server.Pact("web", "users", version)
+		path, err := pact.Save(t.TempDir())
+		assert.Nil(t, err)
+		assert.Equal(t, "web-users.json", filepath.Base(path))
+
+		data, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		var document map[string]interface{}
+		assert.Nil(t, json.Unmarshal(data, &document))
+		assert.Equal(t, string(version), document["metadata"].(map[string]interface{})["pactSpecification"].(map[string]interface{})["version"])
+
+		interaction := document["interactions"].([]interface{})[1].(map[string]interface{})
+		assert.Equal(t, []interface{}{map[string]interface{}{"name": "user 1 exists"}}, interaction["providerStates"])
+		response := interaction["response"].(map[string]interface{})
+		if version == PactV4 {
+			assert.Equal(t, "Synchronous/HTTP", interaction["type"])
+			assert.Equal(t, []interface{}{"application/json"}, response["headers"].(map[string]interface{})["Content-Type"])
+			assert.Equal(t, false, response["body"].(map[string]interface{})["encoded"])
+		} else {
+			assert.NotContains(t, interaction, "type")
+			assert.Equal(t, "application/json", response["headers"].(map[string]interface{})["Content-Type"])
+			assert.Equal(t, map[string]interface{}{"id": float64(1), "name": "kalt"}, response["body"])
+		}
+
+		loaded, err := LoadPact(path)
+		assert.Nil(t, err)
+		assert.Equal(t, pact.Interactions, loaded.Interactions)
+		assert.Equal(t, version, loaded.Version)
+	}
+}
+
+func TestVerifyPact(t *testing.T) {
+	consumer := newPactConsumerServer(t)
+	defer consumer.Close()
+
+	pactPath, err := consumer.Pact("web", "users", PactV4).Save(t.TempDir())
+	assert.Nil(t, err)
+
+	var stateSetUp bool
+	states := ProviderStates{"user 1 exists": func() error {
+		stateSetUp = true
+		return nil
+	}}
+
+	provider := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/users/1" && r.URL.Query().Get("fields") == "name":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":7,"name":"another","email":"a@b.c"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/users":
+			w.Header().Set("Location", "/users/2")
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	assert.True(t, VerifyPact(t, pactPath, provider, states))
+	assert.True(t, stateSetUp)
+
+	brokenProvider := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(`{"id":"7"}`))
+	})
+	tester := &testing.T{}
+	assert.False(t, VerifyPact(tester, pactPath, brokenProvider, states))
+
+	pact, err := LoadPact(pactPath)
+	assert.Nil(t, err)
+	errs := pact.Verify(brokenProvider, states)
+	assert.Contains(t, errs, errors.New(`GET /users/1: header Content-Type: value: "text/plain" does not match "^application/json"`))
+	assert.Contains(t, errs, errors.New(`GET /users/1: body $.id: expected number, got string`))
+	assert.Contains(t, errs, errors.New(`GET /users/1: body $.name: missing`))
+	assert.Contains(t, errs, errors.New(`POST /users: expected status 201, got 200`))
+	assert.Contains(t, errs, errors.New(`POST /users: missing header Location`))
+
+	errs = pact.Verify(provider, nil)
+	assert.Contains(t, errs, errors.New(`GET /users/1: no handler for provider state "user 1 exists"`))
+
+	errs = pact.Verify(provider, ProviderStates{"user 1 exists": func() error { return errors.New("database down") }})
+	assert.Contains(t, errs, errors.New(`GET /users/1: provider state "user 1 exists": database down`))
+}
+
+func TestPactVerifyMatchers(t *testing.T) {
+	pactPath := filepath.Join(t.TempDir(), "pact.json")
+	assert.Nil(t, ioutil.WriteFile(pactPath, []byte(`{
+  "consumer": {"name": "web"},
+  "provider": {"name": "books"},
+  "interactions": [{
+    "description": "list books",
+    "providerState": "books exist",
+    "request": {"method": "get", "path": "/books", "query": "page=2&size=10"},
+    "response": {
+      "status": 200,
+      "headers": {"X-Request-Id": "abc-123"},
+      "body": {"total": 1, "books": [{"isbn": "978-0", "title": "Aduket", "tags": ["go"]}], "next": "/books?page=3"},
+      "matchingRules": {
+        "header": {"X-Request-Id": {"matchers": [{"match": "regex", "regex": "^[a-z]+-\\d+$"}]}},
+        "body": {
+          "$.total": {"matchers": [{"match": "integer"}]},
+          "$.books": {"matchers": [{"match": "type", "min": 1}]},
+          "$.books[*].isbn": {"matchers": [{"match": "regex", "regex": "^\\d{3}-\\d$"}]},
+          "$.books[*].tags": {"matchers": [{"match": "equality"}]},
+          "$.next": {"combine": "OR", "matchers": [{"match": "include", "value": "page="}, {"match": "null"}]}
+        }
+      }
+    }
+  }],
+  "metadata": {"pactSpecification": {"version": "2.0.0"}}
+}`), 0644))
+
+	pact, err := LoadPact(pactPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "books exist", pact.Interactions[0].ProviderState)
+	assert.Equal(t, http.MethodGet, pact.Interactions[0].Request.Method)
+
+	states := ProviderStates{"books exist": func() error { return nil }}
+	respond := func(requestID, body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") != "2" || r.URL.Query().Get("size") != "10" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("X-Request-Id", requestID)
+			w.Write([]byte(body))
+		})
+	}
+
+	errs := pact.Verify(respond("xyz-987", `{"total": 2, "books": [{"isbn": "123-4", "title": "Other", "tags": ["go"]}, {"isbn": "555-5", "title": "Third", "tags": ["go"]}], "next": null}`), states)
+	assert.Empty(t, errs)
+
+	errs = pact.Verify(respond("XYZ", `{"total": 2.5, "books": [], "next": "/books"}`), states)
+	assert.Equal(t, []error{
+		errors.New(`list books: header X-Request-Id: value: "XYZ" does not match "^[a-z]+-\\d+$"`),
+		errors.New(`list books: body $.books: expected at least 1 elements, got 0`),
+		errors.New(`list books: body $.next: "/books" does not include "page="`),
+		errors.New(`list books: body $.next: expected null, got string`),
+		errors.New(`list books: body $.total: expected integer, got 2.5`),
+	}, errs)
+
+	errs = pact.Verify(respond("xyz-987", `{"total": 1, "books": [{"isbn": "bad", "title": 1, "tags": ["rust"]}], "next": null}`), states)
+	assert.Equal(t, []error{
+		errors.New(`list books: body $.books[0].isbn: "bad" does not match "^\\d{3}-\\d$"`),
+		errors.New(`list books: body $.books[0].tags[0]: expected "go", got "rust"`),
+		errors.New(`list books: body $.books[0].title: expected string, got number`),
+	}, errs)
+}
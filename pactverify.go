@@ -0,0 +1,383 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+// ProviderStates sets up the provider for the named states of a Pact before
+// their interactions are replayed.
+type ProviderStates map[string]func() error
+
+// VerifyPact replays every interaction of the Pact file against handler and
+// checks the responses honour the contract.
+func VerifyPact(t *testing.T, pactPath string, handler http.Handler, states ProviderStates) bool {
+	pact, err := LoadPact(pactPath)
+	if err != nil {
+		t.Error(err)
+		return false
+	}
+	return assert.Empty(t, pact.Verify(handler, states), "provider does not honour the pact")
+}
+
+// Verify replays the interactions against handler in order and returns one
+// error per mismatch. Only response rules are applied; extra response
+// headers and JSON object keys are allowed, as in the Pact specification.
+func (p *Pact) Verify(handler http.Handler, states ProviderStates) []error {
+	var errs []error
+	for _, interaction := range p.Interactions {
+		for _, mismatch := range interaction.verify(handler, states) {
+			errs = append(errs, fmt.Errorf("%s: %s", interaction.Description, mismatch))
+		}
+	}
+	return errs
+}
+
+func (i PactInteraction) verify(handler http.Handler, states ProviderStates) []string {
+	if i.ProviderState != "" {
+		setUp, ok := states[i.ProviderState]
+		if !ok {
+			return []string{fmt.Sprintf("no handler for provider state %q", i.ProviderState)}
+		}
+		if err := setUp(); err != nil {
+			return []string{fmt.Sprintf("provider state %q: %v", i.ProviderState, err)}
+		}
+	}
+
+	request, err := i.Request.httpRequest()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	return i.Response.mismatches(recorder.Result())
+}
+
+func (r PactRequest) httpRequest() (*http.Request, error) {
+	var body io.Reader
+	switch b := r.Body.(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	target := url.URL{Scheme: "http", Host: "provider", Path: r.Path, RawQuery: r.Query.Encode()}
+	request, err := http.NewRequest(r.Method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range r.Header {
+		request.Header[http.CanonicalHeaderKey(name)] = values
+	}
+	if _, ok := r.Body.(string); !ok && r.Body != nil && request.Header.Get(echo.HeaderContentType) == "" {
+		request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	return request, nil
+}
+
+func (r PactResponse) mismatches(response *http.Response) []string {
+	var mismatches []string
+	if response.StatusCode != r.StatusCode {
+		mismatches = append(mismatches, fmt.Sprintf("expected status %d, got %d", r.StatusCode, response.StatusCode))
+	}
+
+	headerRules, _ := r.MatchingRules["header"].(map[string]interface{})
+	for _, name := range sortedHeaderNames(r.Header) {
+		expected := strings.Join(r.Header[name], ", ")
+		values, ok := response.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("missing header %s", name))
+			continue
+		}
+		actual := strings.Join(values, ", ")
+
+		if rule, ok := lookupHeaderRule(headerRules, name); ok {
+			for _, mismatch := range applyPactRule(rule, expected, actual, nil, nil) {
+				mismatches = append(mismatches, fmt.Sprintf("header %s: %s", name, mismatch))
+			}
+		} else if normalizeHeaderValue(expected) != normalizeHeaderValue(actual) {
+			mismatches = append(mismatches, fmt.Sprintf("header %s: expected %q, got %q", name, expected, actual))
+		}
+	}
+
+	if r.Body == nil {
+		return mismatches
+	}
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return append(mismatches, err.Error())
+	}
+
+	var actual interface{} = string(data)
+	if _, ok := r.Body.(string); !ok {
+		if err := json.Unmarshal(data, &actual); err != nil {
+			return append(mismatches, fmt.Sprintf("body is not JSON: %v", err))
+		}
+	}
+
+	bodyRules, _ := r.MatchingRules["body"].(map[string]interface{})
+	matcher := newPactBodyMatcher(bodyRules)
+	for _, mismatch := range matcher.compare(r.Body, actual, nil, false) {
+		mismatches = append(mismatches, "body "+mismatch)
+	}
+	return mismatches
+}
+
+func lookupHeaderRule(rules map[string]interface{}, name string) (map[string]interface{}, bool) {
+	for ruleName, rule := range rules {
+		if strings.EqualFold(ruleName, name) {
+			rule, ok := rule.(map[string]interface{})
+			return rule, ok
+		}
+	}
+	return nil, false
+}
+
+func normalizeHeaderValue(value string) string {
+	return strings.Join(splitHeaderList(value), ",")
+}
+
+type pactBodyRule struct {
+	path []jsonPathSegment
+	rule map[string]interface{}
+}
+
+type pactBodyMatcher struct {
+	rules []pactBodyRule
+}
+
+func newPactBodyMatcher(rules map[string]interface{}) *pactBodyMatcher {
+	matcher := &pactBodyMatcher{}
+	for _, key := range sortedKeys(rules) {
+		rule, ok := rules[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if path, ok := parseJSONPath(key); ok {
+			matcher.rules = append(matcher.rules, pactBodyRule{path: path, rule: rule})
+		}
+	}
+	return matcher
+}
+
+// rule returns the most specific rule for path, the one with the fewest
+// wildcards.
+func (m *pactBodyMatcher) rule(path []jsonPathSegment) (map[string]interface{}, bool) {
+	var best map[string]interface{}
+	bestWildcards := -1
+	for _, candidate := range m.rules {
+		wildcards, matches := matchJSONPath(candidate.path, path)
+		if matches && (bestWildcards < 0 || wildcards < bestWildcards) {
+			best, bestWildcards = candidate.rule, wildcards
+		}
+	}
+	return best, best != nil
+}
+
+// compare matches actual against expected at path. Type matchers cascade to
+// children that have no rule of their own.
+func (m *pactBodyMatcher) compare(expected, actual interface{}, path []jsonPathSegment, cascadeType bool) []string {
+	if rule, ok := m.rule(path); ok {
+		return applyPactRule(rule, expected, actual, m, path)
+	}
+	if cascadeType {
+		return m.compareType(expected, actual, path)
+	}
+	return m.compareEqual(expected, actual, path)
+}
+
+func (m *pactBodyMatcher) compareEqual(expected, actual interface{}, path []jsonPathSegment) []string {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actualObject, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %s", formatJSONPath(path), jsonKind(actual))}
+		}
+		return m.compareObject(expected, actualObject, path, false)
+	case []interface{}:
+		actualArray, ok := actual.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %s", formatJSONPath(path), jsonKind(actual))}
+		}
+		if len(actualArray) != len(expected) {
+			return []string{fmt.Sprintf("%s: expected %d elements, got %d", formatJSONPath(path), len(expected), len(actualArray))}
+		}
+		var mismatches []string
+		for i := range expected {
+			mismatches = append(mismatches, m.compare(expected[i], actualArray[i], appendJSONPath(path, jsonPathSegment{index: i, isIndex: true}), false)...)
+		}
+		return mismatches
+	default:
+		if !reflect.DeepEqual(expected, actual) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", formatJSONPath(path), jsonString(expected), jsonString(actual))}
+		}
+		return nil
+	}
+}
+
+func (m *pactBodyMatcher) compareType(expected, actual interface{}, path []jsonPathSegment) []string {
+	if jsonKind(expected) != jsonKind(actual) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", formatJSONPath(path), jsonKind(expected), jsonKind(actual))}
+	}
+
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		return m.compareObject(expected, actual.(map[string]interface{}), path, true)
+	case []interface{}:
+		if len(expected) == 0 {
+			return nil
+		}
+		var mismatches []string
+		for i, element := range actual.([]interface{}) {
+			mismatches = append(mismatches, m.compare(expected[0], element, appendJSONPath(path, jsonPathSegment{index: i, isIndex: true}), true)...)
+		}
+		return mismatches
+	}
+	return nil
+}
+
+func (m *pactBodyMatcher) compareObject(expected, actual map[string]interface{}, path []jsonPathSegment, cascadeType bool) []string {
+	var mismatches []string
+	for _, key := range sortedKeys(expected) {
+		childPath := appendJSONPath(path, jsonPathSegment{key: key})
+		value, ok := actual[key]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: missing", formatJSONPath(childPath)))
+			continue
+		}
+		mismatches = append(mismatches, m.compare(expected[key], value, childPath, cascadeType)...)
+	}
+	return mismatches
+}
+
+// applyPactRule runs the rule's matchers, combined with AND unless the rule
+// says OR. body is nil for header values, which have no children.
+func applyPactRule(rule map[string]interface{}, expected, actual interface{}, body *pactBodyMatcher, path []jsonPathSegment) []string {
+	matchers, _ := rule["matchers"].([]interface{})
+	combineOr := rule["combine"] == "OR"
+
+	var mismatches []string
+	for _, matcher := range matchers {
+		matcher, ok := matcher.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		failures := applyPactMatcher(matcher, expected, actual, body, path)
+		if combineOr && len(failures) == 0 {
+			return nil
+		}
+		mismatches = append(mismatches, failures...)
+	}
+	return mismatches
+}
+
+func applyPactMatcher(matcher map[string]interface{}, expected, actual interface{}, body *pactBodyMatcher, path []jsonPathSegment) []string {
+	location := formatJSONPath(path)
+	if body == nil {
+		location = "value"
+	}
+	fail := func(format string, args ...interface{}) []string {
+		return []string{location + ": " + fmt.Sprintf(format, args...)}
+	}
+
+	number, isNumber := actual.(float64)
+	switch match, _ := matcher["match"].(string); match {
+	case "type":
+		if array, ok := actual.([]interface{}); ok {
+			if min, ok := matcher["min"].(float64); ok && float64(len(array)) < min {
+				return fail("expected at least %v elements, got %d", min, len(array))
+			}
+			if max, ok := matcher["max"].(float64); ok && float64(len(array)) > max {
+				return fail("expected at most %v elements, got %d", max, len(array))
+			}
+		}
+		if body == nil {
+			return nil
+		}
+		return body.compareType(expected, actual, path)
+	case "equality":
+		if body == nil {
+			if expected != actual {
+				return fail("expected %q, got %q", expected, actual)
+			}
+			return nil
+		}
+		return body.compareEqual(expected, actual, path)
+	case "regex":
+		pattern, _ := matcher["regex"].(string)
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fail("invalid regex %q: %v", pattern, err)
+		}
+		if value := scalarString(actual); !re.MatchString(value) {
+			return fail("%q does not match %q", value, pattern)
+		}
+	case "include":
+		value, _ := matcher["value"].(string)
+		if !strings.Contains(scalarString(actual), value) {
+			return fail("%q does not include %q", scalarString(actual), value)
+		}
+	case "number", "decimal":
+		if !isNumber {
+			return fail("expected number, got %s", jsonKind(actual))
+		}
+	case "integer":
+		if !isNumber || number != math.Trunc(number) {
+			return fail("expected integer, got %s", jsonString(actual))
+		}
+	case "boolean":
+		if _, ok := actual.(bool); !ok {
+			return fail("expected boolean, got %s", jsonKind(actual))
+		}
+	case "null":
+		if actual != nil {
+			return fail("expected null, got %s", jsonKind(actual))
+		}
+	default:
+		return fail("unsupported matcher %q", match)
+	}
+	return nil
+}
+
+func scalarString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return jsonString(value)
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type PaginationStyle int
+
+const (
+	// OffsetPagination reads ?offset=&limit= and answers with
+	// {"items", "offset", "limit", "total"}.
+	OffsetPagination PaginationStyle = iota
+	// PageNumberPagination reads ?page=&per_page= (1-based) and answers with
+	// {"items", "page", "per_page", "total", "total_pages"}.
+	PageNumberPagination
+	// CursorPagination reads ?cursor= and answers with {"items", "next_cursor"}.
+	// next_cursor is omitted on the last page.
+	CursorPagination
+	// LinkHeaderPagination reads ?page=&per_page= and answers with the bare
+	// items array and an RFC 5988 Link header.
+	LinkHeaderPagination
+)
+
+const (
+	paginationOffsetKey  = "offset"
+	paginationLimitKey   = "limit"
+	paginationPageKey    = "page"
+	paginationPerPageKey = "per_page"
+	paginationCursorKey  = "cursor"
+)
+
+type pagination struct {
+	items    []json.RawMessage
+	pageSize int
+	style    PaginationStyle
+}
+
+func newPagination(items interface{}, pageSize int, style PaginationStyle) *pagination {
+	if pageSize <= 0 {
+		panic("aduket: page size must be positive")
+	}
+
+	b, err := json.Marshal(items)
+	if err != nil {
+		panic(fmt.Sprintf("aduket: paginated items could not be marshaled to json: %v", err))
+	}
+
+	p := &pagination{pageSize: pageSize, style: style}
+	if err := json.Unmarshal(b, &p.items); err != nil {
+		panic("aduket: paginated items must be a slice")
+	}
+	return p
+}
+
+func (p *pagination) pageCount(pageSize int) int {
+	if len(p.items) == 0 {
+		return 1
+	}
+	return (len(p.items) + pageSize - 1) / pageSize
+}
+
+func (p *pagination) respond(ctx echo.Context, requestRecorder *RequestRecorder) (int, interface{}) {
+	queryParams := ctx.QueryParams()
+
+	switch p.style {
+	case OffsetPagination:
+		offset, ok := nonNegativeQueryParam(queryParams, paginationOffsetKey, 0)
+		limit, limitOK := nonNegativeQueryParam(queryParams, paginationLimitKey, p.pageSize)
+		if !ok || !limitOK {
+			return http.StatusBadRequest, resourceError("invalid offset or limit")
+		}
+		if limit == 0 || limit > p.pageSize {
+			limit = p.pageSize
+		}
+
+		requestRecorder.recordPage(offset/limit+1, p.pageCount(limit))
+		return http.StatusOK, map[string]interface{}{
+			"items":  p.slice(offset, limit),
+			"offset": offset,
+			"limit":  limit,
+			"total":  len(p.items),
+		}
+	case CursorPagination:
+		offset := 0
+		if cursor := queryParams.Get(paginationCursorKey); cursor != "" {
+			var ok bool
+			if offset, ok = decodeCursor(cursor); !ok {
+				return http.StatusBadRequest, resourceError("invalid cursor")
+			}
+		}
+
+		requestRecorder.recordPage(offset/p.pageSize+1, p.pageCount(p.pageSize))
+		body := map[string]interface{}{"items": p.slice(offset, p.pageSize)}
+		if offset+p.pageSize < len(p.items) {
+			body["next_cursor"] = encodeCursor(offset + p.pageSize)
+		}
+		return http.StatusOK, body
+	}
+
+	page, ok := positiveQueryParam(queryParams, paginationPageKey, 1)
+	perPage, perPageOK := positiveQueryParam(queryParams, paginationPerPageKey, p.pageSize)
+	if !ok || !perPageOK {
+		return http.StatusBadRequest, resourceError("invalid page or per_page")
+	}
+	if perPage > p.pageSize {
+		perPage = p.pageSize
+	}
+
+	requestRecorder.recordPage(page, p.pageCount(perPage))
+	items := p.slice((page-1)*perPage, perPage)
+
+	if p.style == LinkHeaderPagination {
+		ctx.Response().Header().Set(resourceTotalCount, strconv.Itoa(len(p.items)))
+		ctx.Response().Header().Set("Link", p.linkHeader(ctx.Request(), page, perPage))
+		return http.StatusOK, items
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"items":       items,
+		"page":        page,
+		"per_page":    perPage,
+		"total":       len(p.items),
+		"total_pages": p.pageCount(perPage),
+	}
+}
+
+func (p *pagination) slice(offset, limit int) []json.RawMessage {
+	if offset >= len(p.items) {
+		return []json.RawMessage{}
+	}
+	end := offset + limit
+	if end > len(p.items) {
+		end = len(p.items)
+	}
+	return p.items[offset:end]
+}
+
+func (p *pagination) linkHeader(request *http.Request, page, perPage int) string {
+	lastPage := p.pageCount(perPage)
+
+	link := func(page int, rel string) string {
+		u := url.URL{Scheme: "http", Host: request.Host, Path: request.URL.Path}
+		if request.TLS != nil {
+			u.Scheme = "https"
+		}
+		query := request.URL.Query()
+		query.Set(paginationPageKey, strconv.Itoa(page))
+		query.Set(paginationPerPageKey, strconv.Itoa(perPage))
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if page > 1 {
+		links = append(links, link(page-1, "prev"))
+	}
+	if page < lastPage {
+		links = append(links, link(page+1, "next"))
+	}
+	links = append(links, link(lastPage, "last"))
+
+	return strings.Join(links, ", ")
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(b), "offset:") {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(b), "offset:"))
+	return offset, err == nil && offset >= 0
+}
+
+func nonNegativeQueryParam(queryParams url.Values, key string, defaultValue int) (int, bool) {
+	value := queryParams.Get(key)
+	if value == "" {
+		return defaultValue, true
+	}
+	n, err := strconv.Atoi(value)
+	return n, err == nil && n >= 0
+}
+
+func positiveQueryParam(queryParams url.Values, key string, defaultValue int) (int, bool) {
+	n, ok := nonNegativeQueryParam(queryParams, key, defaultValue)
+	return n, ok && n > 0
+}
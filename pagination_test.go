@@ -0,0 +1,118 @@
+package aduket
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var paginatedUsers = []User{{1, "ken"}, {2, "ryu"}, {3, "guile"}, {4, "blanka"}, {5, "zangief"}}
+
+type paginatedPage struct {
+	Items      []User `json:"items"`
+	Total      int    `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	NextCursor string `json:"next_cursor"`
+}
+
+func TestPaginateOffset(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodGet, "/users", Paginate(paginatedUsers, 2, OffsetPagination))
+	defer server.Close()
+
+	walked := []User{}
+	for offset := 0; ; offset += 2 {
+		page := getPage(t, server.URL+"/users?offset="+strconv.Itoa(offset)+"&limit=2")
+		walked = append(walked, page.Items...)
+		if offset+2 >= page.Total {
+			break
+		}
+	}
+
+	assert.Equal(t, paginatedUsers, walked)
+	assert.Equal(t, []int{1, 2, 3}, requestRecorder.PagesServed)
+
+	tester := &testing.T{}
+	assert.True(t, requestRecorder.AssertAllPagesWalkedOnce(tester))
+	assert.False(t, tester.Failed())
+
+	getPage(t, server.URL+"/users?offset=2")
+	assert.False(t, requestRecorder.AssertAllPagesWalkedOnce(tester))
+	assert.True(t, tester.Failed())
+}
+
+func TestPaginatePageNumber(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodGet, "/users", Paginate(paginatedUsers, 2, PageNumberPagination))
+	defer server.Close()
+
+	page := getPage(t, server.URL+"/users?page=3")
+	assert.Equal(t, []User{{5, "zangief"}}, page.Items)
+	assert.Equal(t, 3, page.TotalPages)
+
+	tester := &testing.T{}
+	assert.False(t, requestRecorder.AssertAllPagesWalkedOnce(tester))
+	assert.True(t, tester.Failed())
+}
+
+func TestPaginateCursor(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodGet, "/users", Paginate(paginatedUsers, 3, CursorPagination))
+	defer server.Close()
+
+	walked := []User{}
+	url := server.URL + "/users"
+	for {
+		page := getPage(t, url)
+		walked = append(walked, page.Items...)
+		if page.NextCursor == "" {
+			break
+		}
+		url = server.URL + "/users?cursor=" + page.NextCursor
+	}
+
+	assert.Equal(t, paginatedUsers, walked)
+	requestRecorder.AssertAllPagesWalkedOnce(t)
+
+	response, err := http.Get(server.URL + "/users?cursor=bogus")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestPaginateLinkHeader(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodGet, "/users", Paginate(paginatedUsers, 2, LinkHeaderPagination))
+	defer server.Close()
+
+	nextLink := regexp.MustCompile(`<([^>]+)>; rel="next"`)
+
+	walked := []User{}
+	url := server.URL + "/users"
+	for url != "" {
+		response, err := http.Get(url)
+		assert.Nil(t, err)
+		assert.Equal(t, "5", response.Header.Get("X-Total-Count"))
+
+		items := []User{}
+		json.NewDecoder(response.Body).Decode(&items)
+		walked = append(walked, items...)
+
+		url = ""
+		if match := nextLink.FindStringSubmatch(response.Header.Get("Link")); match != nil {
+			url = match[1]
+		}
+	}
+
+	assert.Equal(t, paginatedUsers, walked)
+	requestRecorder.AssertAllPagesWalkedOnce(t)
+}
+
+func getPage(t *testing.T, url string) paginatedPage {
+	response, err := http.Get(url)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	page := paginatedPage{}
+	json.NewDecoder(response.Body).Decode(&page)
+	return page
+}
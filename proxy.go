@@ -0,0 +1,425 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/labstack/echo"
+)
+
+// CassetteMatch selects the parts of a request a replay server compares with
+// the recorded interactions.
+type CassetteMatch int
+
+const (
+	MatchMethod CassetteMatch = 1 << iota
+	MatchPath
+	MatchQuery
+	MatchBody
+
+	DefaultCassetteMatch = MatchMethod | MatchPath | MatchQuery
+)
+
+// Cassette is the file format of recorded interactions.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest holds URL as path and query. Bodies that are not valid UTF-8
+// are stored base64 encoded with BodyEncoding set to "base64".
+type CassetteRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+type CassetteResponse struct {
+	StatusCode   int         `json:"statusCode"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+type ProxyOption func(*proxy)
+
+// MatchOn replaces DefaultCassetteMatch when replaying.
+func MatchOn(match CassetteMatch) ProxyOption {
+	return func(p *proxy) {
+		p.match = match
+	}
+}
+
+// MatchHeaders additionally requires the given request headers to equal the
+// recorded ones when replaying. Recording with it writes redacted headers,
+// such as Authorization, as a SHA-256 hash so they can still be matched
+// without the secret ending up in the cassette.
+func MatchHeaders(names ...string) ProxyOption {
+	return func(p *proxy) {
+		p.headers = append(p.headers, names...)
+	}
+}
+
+// RedactHeaders keeps the given headers out of recorded cassettes, in addition
+// to Authorization, Cookie and Set-Cookie which are never written unless
+// RecordHeaders asks for them. Requests are still forwarded with them.
+func RedactHeaders(names ...string) ProxyOption {
+	return func(p *proxy) {
+		for _, name := range names {
+			p.redacted[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// RecordHeaders writes the given headers to cassettes even if they are
+// redacted by default.
+func RecordHeaders(names ...string) ProxyOption {
+	return func(p *proxy) {
+		for _, name := range names {
+			delete(p.redacted, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+type proxy struct {
+	upstream     *url.URL
+	cassettePath string
+	match        CassetteMatch
+	headers      []string
+	redacted     map[string]bool
+	client       *http.Client
+	recorder     *RequestRecorder
+
+	mu       sync.Mutex
+	cassette *Cassette
+	played   []bool
+}
+
+// NewRecordingProxyServer forwards every request to upstream and appends each
+// exchange to the cassette file, which is rewritten after every request.
+// Credential headers are left out of the cassette, see RedactHeaders.
+func NewRecordingProxyServer(upstream, cassettePath string, options ...ProxyOption) (*httptest.Server, *RequestRecorder) {
+	upstreamURL, err := url.Parse(upstream)
+	if err != nil {
+		panic(err)
+	}
+
+	p := newProxy(cassettePath, options)
+	p.upstream = upstreamURL
+	p.cassette = &Cassette{}
+	p.client = &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return startProxy(p.record), p.recorder
+}
+
+// NewReplayServer answers from a cassette written by NewRecordingProxyServer
+// without contacting the upstream. Each request gets the first matching
+// interaction not played yet, or the last matching one once all were played.
+// Requests matching nothing get 502 Bad Gateway.
+func NewReplayServer(cassettePath string, options ...ProxyOption) (*httptest.Server, *RequestRecorder) {
+	cassette, err := LoadCassette(cassettePath)
+	if err != nil {
+		panic(err)
+	}
+
+	p := newProxy(cassettePath, options)
+	p.cassette = cassette
+	p.played = make([]bool, len(cassette.Interactions))
+
+	return startProxy(p.replay), p.recorder
+}
+
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cassette := &Cassette{}
+	if err := json.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("aduket: invalid cassette %s: %v", path, err)
+	}
+	return cassette, nil
+}
+
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func newProxy(cassettePath string, options []ProxyOption) *proxy {
+	p := &proxy{
+		cassettePath: cassettePath,
+		match:        DefaultCassetteMatch,
+		redacted:     credentialHeaders(),
+		recorder:     NewRequestRecorder(),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+func startProxy(handler echo.HandlerFunc) *httptest.Server {
+	e := createEcho()
+	e.Any("/*", handler)
+	return startServer(e)
+}
+
+func (p *proxy) record(ctx echo.Context) error {
+	p.recorder.markRequestReceived()
+	if err := ctx.Bind(p.recorder); err != nil {
+		return err
+	}
+
+	request := ctx.Request()
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	upstreamURL := *p.upstream
+	upstreamURL.Path = strings.TrimSuffix(p.upstream.Path, "/") + request.URL.Path
+	upstreamURL.RawQuery = request.URL.RawQuery
+
+	upstreamRequest, err := http.NewRequestWithContext(request.Context(), request.Method, upstreamURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	upstreamRequest.Header = withoutHopHeaders(request.Header)
+
+	response, err := p.client.Do(upstreamRequest)
+	if err != nil {
+		p.recorder.setResponseStatus(ctx, http.StatusBadGateway)
+		return ctx.String(http.StatusBadGateway, fmt.Sprintf("aduket: upstream request failed: %v", err))
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		p.recorder.setResponseStatus(ctx, http.StatusBadGateway)
+		return ctx.String(http.StatusBadGateway, fmt.Sprintf("aduket: reading upstream response failed: %v", err))
+	}
+
+	interaction := &Interaction{
+		Request: CassetteRequest{
+			Method: request.Method,
+			URL:    request.URL.RequestURI(),
+			Header: p.redactRequest(request.Header),
+		},
+		Response: CassetteResponse{
+			StatusCode: response.StatusCode,
+			Header:     p.redact(response.Header),
+		},
+	}
+	interaction.Request.Body, interaction.Request.BodyEncoding = encodeCassetteBody(body)
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeCassetteBody(responseBody)
+
+	p.mu.Lock()
+	p.cassette.Interactions = append(p.cassette.Interactions, interaction)
+	err = p.cassette.Save(p.cassettePath)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return p.respond(ctx, response.StatusCode, withoutHopHeaders(response.Header), responseBody)
+}
+
+func (p *proxy) replay(ctx echo.Context) error {
+	p.recorder.markRequestReceived()
+	if err := ctx.Bind(p.recorder); err != nil {
+		return err
+	}
+
+	request := ctx.Request()
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+
+	interaction := p.nextInteraction(request, body)
+	if interaction == nil {
+		p.recorder.setResponseStatus(ctx, http.StatusBadGateway)
+		return ctx.String(http.StatusBadGateway, fmt.Sprintf("aduket: no interaction in %s matches %s %s", p.cassettePath, request.Method, request.URL.RequestURI()))
+	}
+
+	responseBody, err := decodeCassetteBody(interaction.Response.Body, interaction.Response.BodyEncoding)
+	if err != nil {
+		return err
+	}
+	return p.respond(ctx, interaction.Response.StatusCode, interaction.Response.Header, responseBody)
+}
+
+func (p *proxy) respond(ctx echo.Context, statusCode int, header http.Header, body []byte) error {
+	for name, values := range header {
+		if http.CanonicalHeaderKey(name) == echo.HeaderContentLength {
+			continue
+		}
+		for _, value := range values {
+			ctx.Response().Header().Add(name, value)
+		}
+	}
+
+	p.recorder.setResponseStatus(ctx, statusCode)
+	ctx.Response().WriteHeader(statusCode)
+	_, err := ctx.Response().Write(body)
+	return err
+}
+
+func (p *proxy) nextInteraction(request *http.Request, body []byte) *Interaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	last := -1
+	for i, interaction := range p.cassette.Interactions {
+		if !p.matches(interaction.Request, request, body) {
+			continue
+		}
+		if !p.played[i] {
+			p.played[i] = true
+			return interaction
+		}
+		last = i
+	}
+	if last < 0 {
+		return nil
+	}
+	return p.cassette.Interactions[last]
+}
+
+func (p *proxy) matches(recorded CassetteRequest, request *http.Request, body []byte) bool {
+	recordedURL, err := url.ParseRequestURI(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	if p.match&MatchMethod != 0 && recorded.Method != request.Method {
+		return false
+	}
+	if p.match&MatchPath != 0 && recordedURL.Path != request.URL.Path {
+		return false
+	}
+	if p.match&MatchQuery != 0 && recordedURL.Query().Encode() != request.URL.Query().Encode() {
+		return false
+	}
+	if p.match&MatchBody != 0 {
+		recordedBody, err := decodeCassetteBody(recorded.Body, recorded.BodyEncoding)
+		if err != nil || !bytes.Equal(recordedBody, body) {
+			return false
+		}
+	}
+	for _, name := range p.headers {
+		recordedValue := strings.Join(recorded.Header.Values(name), ",")
+		requestValue := strings.Join(request.Header.Values(name), ",")
+		if strings.HasPrefix(recordedValue, headerHashPrefix) {
+			requestValue = hashHeaderValue(requestValue)
+		}
+		if recordedValue != requestValue {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeCassetteBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeCassetteBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
+
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// credentialHeaders returns the headers cassettes and HAR files leave out by
+// default.
+func credentialHeaders() map[string]bool {
+	return map[string]bool{
+		echo.HeaderAuthorization: true,
+		echo.HeaderCookie:        true,
+		echo.HeaderSetCookie:     true,
+		"Proxy-Authorization":    true,
+	}
+}
+
+func (p *proxy) redact(header http.Header) http.Header {
+	return withoutHeaders(withoutHopHeaders(header), p.redacted)
+}
+
+// redactRequest is redact keeping a hash of the redacted headers MatchHeaders
+// compares.
+func (p *proxy) redactRequest(header http.Header) http.Header {
+	redacted := p.redact(header)
+	for _, name := range p.headers {
+		name = http.CanonicalHeaderKey(name)
+		if p.redacted[name] && len(header.Values(name)) != 0 {
+			redacted.Set(name, hashHeaderValue(strings.Join(header.Values(name), ",")))
+		}
+	}
+	return redacted
+}
+
+const headerHashPrefix = "sha256:"
+
+func hashHeaderValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return headerHashPrefix + hex.EncodeToString(sum[:])
+}
+
+func withoutHopHeaders(header http.Header) http.Header {
+	cloned := header.Clone()
+	for _, name := range hopHeaders {
+		cloned.Del(name)
+	}
+	return cloned
+}
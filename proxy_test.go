@@ -0,0 +1,217 @@
+package aduket
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "users.json")
+
+	upstream, upstreamRecorders := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/users"}: {
+			JSONBody([]User{{ID: 1, Name: "kalt"}}),
+			Header(http.Header{"Content-Type": []string{"application/json"}}),
+		},
+		{HttpMethod: http.MethodPost, Path: "/users"}: {StatusCode(http.StatusCreated), StringBody("created")},
+		{HttpMethod: http.MethodGet, Path: "/avatar"}: {ByteBody([]byte{0xff, 0xd8, 0xff})},
+	})
+
+	proxy, proxyRecorder := NewRecordingProxyServer(upstream.URL, cassettePath)
+	response, err := http.Get(proxy.URL + "/users?active=true")
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.Equal(t, string(jsonMarshal([]User{{ID: 1, Name: "kalt"}})), string(body))
+
+	_, err = http.Post(proxy.URL+"/users", "application/json", strings.NewReader(`{"name":"kalt"}`))
+	assert.Nil(t, err)
+	_, err = http.Get(proxy.URL + "/avatar")
+	assert.Nil(t, err)
+	proxy.Close()
+	upstream.Close()
+
+	upstreamRecorders[Route{HttpMethod: http.MethodPost, Path: "/users"}].AssertStringBodyEqual(t, `{"name":"kalt"}`)
+	assert.Equal(t, "true", proxyRecorder.Requests[0].QueryParams.Get("active"))
+	assert.Len(t, proxyRecorder.Requests, 3)
+
+	cassette, err := LoadCassette(cassettePath)
+	assert.Nil(t, err)
+	assert.Len(t, cassette.Interactions, 3)
+	assert.Equal(t, "/users?active=true", cassette.Interactions[0].Request.URL)
+	assert.Equal(t, "base64", cassette.Interactions[2].Response.BodyEncoding)
+
+	replay, replayRecorder := NewReplayServer(cassettePath)
+	defer replay.Close()
+
+	response, err = http.Get(replay.URL + "/users?active=true")
+	assert.Nil(t, err)
+	body, _ = ioutil.ReadAll(response.Body)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.Equal(t, string(jsonMarshal([]User{{ID: 1, Name: "kalt"}})), string(body))
+
+	response, err = http.Post(replay.URL+"/users", "application/json", strings.NewReader(`{"name":"other"}`))
+	assert.Nil(t, err)
+	body, _ = ioutil.ReadAll(response.Body)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "created", string(body))
+	replayRecorder.AssertStringBodyEqual(t, `{"name":"other"}`)
+
+	response, err = http.Get(replay.URL + "/avatar")
+	assert.Nil(t, err)
+	body, _ = ioutil.ReadAll(response.Body)
+	assert.Equal(t, []byte{0xff, 0xd8, 0xff}, body)
+
+	response, err = http.Get(replay.URL + "/users")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadGateway, response.StatusCode)
+	assert.Equal(t, http.StatusBadGateway, replayRecorder.Requests[3].StatusCode)
+}
+
+func TestRecordingProxyRedactsHeaders(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "session.json")
+
+	upstream, upstreamRecorder := NewServer(http.MethodGet, "/me", Header(http.Header{"Set-Cookie": []string{"session=secret"}}))
+	defer upstream.Close()
+
+	proxy, _ := NewRecordingProxyServer(upstream.URL, cassettePath, RedactHeaders("X-Api-Key"), RecordHeaders("Cookie"))
+	defer proxy.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, proxy.URL+"/me", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	request.Header.Set("Cookie", "theme=dark")
+	request.Header.Set("X-Api-Key", "secret")
+	request.Header.Set("X-Tenant", "acme")
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "session=secret", response.Header.Get("Set-Cookie"))
+	upstreamRecorder.AssertHeaderContains(t, http.Header{"Authorization": []string{"Bearer secret"}, "X-Api-Key": []string{"secret"}})
+
+	cassette, err := LoadCassette(cassettePath)
+	assert.Nil(t, err)
+	recorded := cassette.Interactions[0]
+	assert.Empty(t, recorded.Request.Header.Get("Authorization"))
+	assert.Empty(t, recorded.Request.Header.Get("X-Api-Key"))
+	assert.Equal(t, "theme=dark", recorded.Request.Header.Get("Cookie"))
+	assert.Equal(t, "acme", recorded.Request.Header.Get("X-Tenant"))
+	assert.Empty(t, recorded.Response.Header.Get("Set-Cookie"))
+}
+
+func TestMatchHeadersOnRedactedHeader(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "auth.json")
+
+	upstream, _ := NewServer(http.MethodGet, "/me", StringBody("ken"))
+	defer upstream.Close()
+	proxy, _ := NewRecordingProxyServer(upstream.URL, cassettePath, MatchHeaders("authorization"))
+
+	request, _ := http.NewRequest(http.MethodGet, proxy.URL+"/me", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	_, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	proxy.Close()
+
+	cassette, err := LoadCassette(cassettePath)
+	assert.Nil(t, err)
+	recorded := cassette.Interactions[0].Request.Header.Get("Authorization")
+	assert.True(t, strings.HasPrefix(recorded, "sha256:"), recorded)
+	assert.NotContains(t, recorded, "secret")
+
+	replay, _ := NewReplayServer(cassettePath, MatchHeaders("Authorization"))
+	defer replay.Close()
+
+	request, _ = http.NewRequest(http.MethodGet, replay.URL+"/me", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, "ken", string(body))
+
+	request.Header.Set("Authorization", "Bearer other")
+	response, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadGateway, response.StatusCode)
+}
+
+func TestReplayPlaysMatchingInteractionsInOrder(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "counter.json")
+	cassette := &Cassette{Interactions: []*Interaction{
+		{Request: CassetteRequest{Method: http.MethodGet, URL: "/counter"}, Response: CassetteResponse{StatusCode: http.StatusOK, Body: "1"}},
+		{Request: CassetteRequest{Method: http.MethodGet, URL: "/counter"}, Response: CassetteResponse{StatusCode: http.StatusOK, Body: "2"}},
+	}}
+	assert.Nil(t, cassette.Save(cassettePath))
+
+	replay, _ := NewReplayServer(cassettePath)
+	defer replay.Close()
+
+	for _, expected := range []string{"1", "2", "2"} {
+		response, err := http.Get(replay.URL + "/counter")
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(response.Body)
+		assert.Equal(t, expected, string(body))
+	}
+}
+
+func TestReplayMatchOptions(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "match.json")
+	cassette := &Cassette{Interactions: []*Interaction{
+		{
+			Request:  CassetteRequest{Method: http.MethodPost, URL: "/search?q=a", Header: http.Header{"X-Tenant": []string{"a"}}, Body: "a"},
+			Response: CassetteResponse{StatusCode: http.StatusOK, Body: "tenant a"},
+		},
+		{
+			Request:  CassetteRequest{Method: http.MethodPost, URL: "/search?q=b", Header: http.Header{"X-Tenant": []string{"b"}}, Body: "b"},
+			Response: CassetteResponse{StatusCode: http.StatusOK, Body: "tenant b"},
+		},
+	}}
+	assert.Nil(t, cassette.Save(cassettePath))
+
+	tests := []struct {
+		options  []ProxyOption
+		url      string
+		tenant   string
+		body     string
+		expected string
+	}{
+		{options: nil, url: "/search?q=b", tenant: "a", body: "a", expected: "tenant b"},
+		{options: []ProxyOption{MatchOn(MatchMethod | MatchPath)}, url: "/search?q=b", tenant: "b", body: "b", expected: "tenant a"},
+		{options: []ProxyOption{MatchOn(MatchPath | MatchBody)}, url: "/search", tenant: "a", body: "b", expected: "tenant b"},
+		{options: []ProxyOption{MatchOn(MatchPath), MatchHeaders("X-Tenant")}, url: "/search", tenant: "b", body: "", expected: "tenant b"},
+		{options: []ProxyOption{MatchHeaders("X-Tenant")}, url: "/search?q=a", tenant: "b", body: "", expected: ""},
+	}
+
+	for _, test := range tests {
+		replay, _ := NewReplayServer(cassettePath, test.options...)
+
+		request, _ := http.NewRequest(http.MethodPost, replay.URL+test.url, strings.NewReader(test.body))
+		request.Header.Set("X-Tenant", test.tenant)
+		response, err := http.DefaultClient.Do(request)
+		assert.Nil(t, err)
+		body, _ := ioutil.ReadAll(response.Body)
+
+		if test.expected == "" {
+			assert.Equal(t, http.StatusBadGateway, response.StatusCode, test.url)
+		} else {
+			assert.Equal(t, test.expected, string(body), test.url)
+		}
+		replay.Close()
+	}
+}
+
+func TestRecordingProxyUpstreamDown(t *testing.T) {
+	upstream, _ := NewServer(http.MethodGet, "/")
+	upstream.Close()
+
+	proxy, proxyRecorder := NewRecordingProxyServer(upstream.URL, filepath.Join(t.TempDir(), "down.json"))
+	defer proxy.Close()
+
+	response, err := http.Get(proxy.URL + "/")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadGateway, response.StatusCode)
+	assert.Equal(t, http.StatusBadGateway, proxyRecorder.Requests[0].StatusCode)
+}
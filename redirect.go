@@ -0,0 +1,169 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	redirectHopKey     = "redirect_hop"
+	redirectJournalKey = "aduket.redirectJournal"
+)
+
+// RedirectHop describes a request that followed a redirect issued by a route
+// of the same server, possibly under another host name such as localhost
+// instead of 127.0.0.1, or of another server sharing its RedirectJournal, and
+// how its headers differ from the request that received the redirect.
+type RedirectHop struct {
+	From           string
+	To             string
+	StatusCode     int
+	KeptHeaders    []string
+	DroppedHeaders []string
+	AddedHeaders   []string
+}
+
+type redirectRule struct {
+	statusCode int
+	location   string
+	chain      int
+	loop       bool
+}
+
+type issuedRedirect struct {
+	from       string
+	statusCode int
+	header     http.Header
+}
+
+// RedirectJournal remembers the redirects a server issued until they are
+// followed. Each server has its own unless servers are given a shared one
+// with ShareRedirects, so hops from one server into another are recorded on
+// the target.
+type RedirectJournal struct {
+	mu     sync.Mutex
+	issued map[string]issuedRedirect
+}
+
+func NewRedirectJournal() *RedirectJournal {
+	return &RedirectJournal{issued: make(map[string]issuedRedirect)}
+}
+
+func (j *RedirectJournal) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		ctx.Set(redirectJournalKey, j)
+		return next(ctx)
+	}
+}
+
+func (j *RedirectJournal) clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.issued = make(map[string]issuedRedirect)
+}
+
+func contextRedirectJournal(ctx echo.Context) *RedirectJournal {
+	journal, _ := ctx.Get(redirectJournalKey).(*RedirectJournal)
+	return journal
+}
+
+// locationFor returns where the request should be redirected, or false once a
+// chain is exhausted and the configured response should be served.
+func (r *redirectRule) locationFor(request *http.Request) (string, bool) {
+	if r.loop {
+		return request.URL.RequestURI(), true
+	}
+
+	if r.chain > 0 {
+		hop, _ := strconv.Atoi(request.URL.Query().Get(redirectHopKey))
+		if hop >= r.chain {
+			return "", false
+		}
+
+		u := *request.URL
+		query := u.Query()
+		query.Set(redirectHopKey, strconv.Itoa(hop+1))
+		u.RawQuery = query.Encode()
+		return u.RequestURI(), true
+	}
+
+	return r.location, true
+}
+
+func (j *RedirectJournal) issue(request *http.Request, location string, statusCode int) {
+	from := requestURL(request)
+	target, err := from.Parse(location)
+	if err != nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.issued[target.String()] = issuedRedirect{
+		from:       from.String(),
+		statusCode: statusCode,
+		header:     request.Header.Clone(),
+	}
+}
+
+func (j *RedirectJournal) follow(request *http.Request) (*RedirectHop, bool) {
+	to := requestURL(request).String()
+
+	j.mu.Lock()
+	issued, ok := j.issued[to]
+	delete(j.issued, to)
+	j.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	hop := &RedirectHop{From: issued.from, To: to, StatusCode: issued.statusCode}
+	for name := range issued.header {
+		if _, ok := request.Header[name]; ok {
+			hop.KeptHeaders = append(hop.KeptHeaders, name)
+		} else {
+			hop.DroppedHeaders = append(hop.DroppedHeaders, name)
+		}
+	}
+	for name := range request.Header {
+		if _, ok := issued.header[name]; !ok {
+			hop.AddedHeaders = append(hop.AddedHeaders, name)
+		}
+	}
+	sort.Strings(hop.KeptHeaders)
+	sort.Strings(hop.DroppedHeaders)
+	sort.Strings(hop.AddedHeaders)
+
+	return hop, true
+}
+
+func requestURL(request *http.Request) *url.URL {
+	u := &url.URL{Scheme: "http", Host: request.Host, Path: request.URL.Path, RawQuery: request.URL.RawQuery}
+	if request.TLS != nil {
+		u.Scheme = "https"
+	}
+	return u
+}
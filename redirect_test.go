@@ -0,0 +1,128 @@
+package aduket
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectAcrossRoutes(t *testing.T) {
+	oldRoute := Route{HttpMethod: http.MethodGet, Path: "/old"}
+	newRoute := Route{HttpMethod: http.MethodGet, Path: "/new"}
+
+	server, requestRecorder := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		oldRoute: {Redirect(http.StatusMovedPermanently, "/new")},
+		newRoute: {StringBody("moved")},
+	})
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/old", http.NoBody)
+	request.Header.Set("Authorization", "Bearer 123")
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	assert.Equal(t, http.StatusMovedPermanently, requestRecorder[oldRoute].Requests[0].StatusCode)
+	assert.Len(t, requestRecorder[newRoute].RedirectHops, 1)
+
+	hop := requestRecorder[newRoute].RedirectHops[0]
+	assert.Equal(t, server.URL+"/old", hop.From)
+	assert.Equal(t, server.URL+"/new", hop.To)
+	assert.Equal(t, http.StatusMovedPermanently, hop.StatusCode)
+	assert.Contains(t, hop.AddedHeaders, "Referer")
+
+	tester := &testing.T{}
+	assert.True(t, requestRecorder[newRoute].AssertHeaderKeptOnRedirect(tester, "authorization"))
+	assert.False(t, tester.Failed())
+}
+
+func TestRedirectAcrossHostsDropsAuthorization(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{})
+	defer server.Close()
+
+	targetRequestRecorder, err := server.AddRoute(Route{HttpMethod: http.MethodGet, Path: "/login"})
+	assert.Nil(t, err)
+	crossHostURL := strings.Replace(server.URL(), "127.0.0.1", "localhost", 1) + "/login"
+	_, err = server.AddRoute(Route{HttpMethod: http.MethodGet, Path: "/start"}, Redirect(http.StatusFound, crossHostURL))
+	assert.Nil(t, err)
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL()+"/start", http.NoBody)
+	request.Header.Set("Authorization", "Bearer 123")
+	request.Header.Set("X-Request-Id", "42")
+
+	_, err = http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+
+	tester := &testing.T{}
+	assert.True(t, targetRequestRecorder.AssertHeaderDroppedOnRedirect(tester, "Authorization"))
+	assert.True(t, targetRequestRecorder.AssertHeaderKeptOnRedirect(tester, "X-Request-Id"))
+	assert.False(t, tester.Failed())
+}
+
+func TestRedirectIntoAnotherServer(t *testing.T) {
+	journal := NewRedirectJournal()
+	targetServer, targetRequestRecorder := NewServerWithOptions(http.MethodGet, "/login", nil, ShareRedirects(journal))
+	defer targetServer.Close()
+	crossHostURL := strings.Replace(targetServer.URL, "127.0.0.1", "localhost", 1) + "/login"
+	server := NewManagedServer(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/start"}: {Redirect(http.StatusFound, crossHostURL)},
+	}, ShareRedirects(journal))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL()+"/start", http.NoBody)
+	request.Header.Set("Authorization", "Bearer 123")
+	request.Header.Set("X-Request-Id", "42")
+
+	_, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	if assert.Len(t, targetRequestRecorder.RedirectHops, 1) {
+		hop := targetRequestRecorder.RedirectHops[0]
+		assert.Equal(t, server.URL()+"/start", hop.From)
+		assert.Equal(t, crossHostURL, hop.To)
+		assert.Equal(t, http.StatusFound, hop.StatusCode)
+		assert.Equal(t, []string{"Authorization"}, hop.DroppedHeaders)
+		assert.Contains(t, hop.KeptHeaders, "X-Request-Id")
+	}
+}
+
+func TestClearRequestsForgetsUnfollowedRedirects(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/start"}: {Redirect(http.StatusFound, "/login")},
+		{HttpMethod: http.MethodGet, Path: "/login"}: {},
+	})
+	defer server.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	_, err := client.Get(server.URL() + "/start")
+	assert.Nil(t, err)
+
+	server.ClearRequests()
+	_, err = http.Get(server.URL() + "/login")
+	assert.Nil(t, err)
+	assert.Empty(t, server.Recorder(Route{HttpMethod: http.MethodGet, Path: "/login"}).RedirectHops)
+}
+
+func TestRedirectChain(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodGet, "/hop", RedirectChain(3), StringBody("done"))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/hop")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	assert.Len(t, requestRecorder.Requests, 4)
+	assert.Len(t, requestRecorder.RedirectHops, 3)
+	assert.Equal(t, server.URL+"/hop?redirect_hop=3", requestRecorder.RedirectHops[2].To)
+}
+
+func TestRedirectLoop(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodGet, "/loop", RedirectLoop())
+	defer server.Close()
+
+	_, err := http.Get(server.URL + "/loop")
+	assert.NotNil(t, err)
+	assert.Len(t, requestRecorder.Requests, 10)
+}
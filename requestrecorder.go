@@ -17,50 +17,113 @@
 package aduket
 
 import (
-	"io"
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/labstack/echo"
 )
 
+// RequestRecorder records the requests a server receives. Its Assert
+// methods take the recorder lock, so they are safe to call while requests
+// are still in flight.
 type RequestRecorder struct {
-	Body              Body
-	Header            http.Header
-	Data              []byte
-	Params            map[string]string
-	QueryParams       url.Values
-	FormParams        url.Values
+	Body        Body
+	Header      http.Header
+	Data        []byte
+	Params      map[string]string
+	QueryParams url.Values
+	FormParams  url.Values
+	TLS         *tls.ConnectionState
+	Protocol    string
+	// ConnectionSeq numbers the connection the last request arrived on,
+	// counting from 1 across every server in the process. It is not an
+	// HTTP/2 stream ID: requests multiplexed on one connection share it.
+	ConnectionSeq     uint64
+	Requests          []*RecordedRequest
+	Preflights        []*PreflightRequest
+	PagesServed       []int
+	RedirectHops      []*RedirectHop
 	isRequestReceived bool
+	pageCount         int
+	mu                *sync.Mutex
+}
+
+type RecordedRequest struct {
+	Method      string
+	Host        string
+	Path        string
+	Body        Body
+	Header      http.Header
+	Params      map[string]string
+	QueryParams url.Values
+	FormParams  url.Values
+	TLS         *tls.ConnectionState
+	Protocol    string
+	// ConnectionSeq numbers the connection the request arrived on. It is
+	// not an HTTP/2 stream ID.
+	ConnectionSeq uint64
+	StatusCode    int
+	SessionID     string
+	ReceivedAt    time.Time
+	// The response, as written to the client.
+	ResponseHeader http.Header
+	ResponseBody   Body
+	Duration       time.Duration
+	// SpecViolations lists how the request breaks the OpenAPI operation it
+	// matched.
+	SpecViolations []string
 }
 
 type Body []byte
 
+const (
+	recordedRequestKey = "aduket.recordedRequest"
+	requestRecorderKey = "aduket.requestRecorder"
+)
+
 func NewRequestRecorder() *RequestRecorder {
 	return &RequestRecorder{
 		Body:   nil,
 		Params: make(map[string]string),
+		mu:     &sync.Mutex{},
 	}
 }
 
 func (r *RequestRecorder) saveContext(ctx echo.Context) error {
-	if ctx.Request().Header.Get(echo.HeaderContentType) == echo.MIMEApplicationXML {
-		r.bindXML(ctx.Request().Body)
-		return nil
-	}
+	isXML := ctx.Request().Header.Get(echo.HeaderContentType) == echo.MIMEApplicationXML
 
 	bodyBytes, err := ioutil.ReadAll(ctx.Request().Body)
 	if err != nil {
 		return err
 	}
+	// Rewind the body so dynamic responders can read it after recording
+	ctx.Request().Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	r.setData(bodyBytes)
+	if !isXML {
+		r.setData(bodyBytes)
+	}
 	r.Body = bodyBytes
 	r.setParams(ctx.ParamNames(), ctx.ParamValues())
 	r.setQueryParams(ctx.QueryParams())
-	r.setFormParams(ctx.Request().Form)
+	r.setFormParams(formParams(ctx.Request(), r.Body))
 	r.setHeader(ctx.Request().Header)
+	r.TLS = ctx.Request().TLS
+	r.Protocol = ctx.Request().Proto
+	r.ConnectionSeq = connectionSeq(ctx.Request())
+	ctx.Set(recordedRequestKey, r.appendRequest(ctx.Request()))
+	ctx.Set(requestRecorderKey, r)
 
 	return nil
 }
@@ -79,6 +142,18 @@ func (r *RequestRecorder) setFormParams(formParams url.Values) {
 	r.FormParams = formParams
 }
 
+func formParams(request *http.Request, body []byte) url.Values {
+	if request.Form != nil || !strings.HasPrefix(request.Header.Get(echo.HeaderContentType), echo.MIMEApplicationForm) {
+		return request.Form
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil
+	}
+	return values
+}
+
 func (r *RequestRecorder) setData(b []byte) {
 	r.Data = b
 }
@@ -87,12 +162,161 @@ func (r *RequestRecorder) setHeader(header http.Header) {
 	r.Header = header
 }
 
-func (r *RequestRecorder) bindXML(from io.ReadCloser) error {
-	body, err := ioutil.ReadAll(from)
-	if err != nil {
-		return err
+func (r *RequestRecorder) appendRequest(request *http.Request) *RecordedRequest {
+	params := make(map[string]string, len(r.Params))
+	for name, value := range r.Params {
+		params[name] = value
 	}
-	r.Body = body
 
-	return nil
+	recorded := &RecordedRequest{
+		Method:        request.Method,
+		Host:          request.Host,
+		Path:          request.URL.Path,
+		Body:          r.Body,
+		Header:        r.Header,
+		Params:        params,
+		QueryParams:   r.QueryParams,
+		FormParams:    r.FormParams,
+		TLS:           r.TLS,
+		Protocol:      r.Protocol,
+		ConnectionSeq: r.ConnectionSeq,
+		ReceivedAt:    time.Now(),
+	}
+	r.Requests = append(r.Requests, recorded)
+
+	return recorded
+}
+
+// setResponseStatus stores the status answered to the request recorded in ctx.
+func (r *RequestRecorder) setResponseStatus(ctx echo.Context, statusCode int) {
+	request := recordedRequest(ctx)
+	if request == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	request.StatusCode = statusCode
+}
+
+func (r *RequestRecorder) addSpecViolations(request *RecordedRequest, violations []string) {
+	if len(violations) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	request.SpecViolations = append(request.SpecViolations, violations...)
+}
+
+func (r *RequestRecorder) setResponse(request *RecordedRequest, statusCode int, header http.Header, body []byte, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	request.StatusCode = statusCode
+	request.ResponseHeader = header
+	request.ResponseBody = body
+	request.Duration = duration
+}
+
+// captureResponse keeps a copy of the response of recorded requests. Responses
+// to requests nothing records, such as admin API calls, are not buffered.
+func captureResponse(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		started := time.Now()
+		capture := &responseCapture{ResponseWriter: ctx.Response().Writer, ctx: ctx}
+		ctx.Response().Writer = capture
+
+		// Handle errors here so error responses are captured too.
+		if err := next(ctx); err != nil {
+			ctx.Error(err)
+		}
+
+		recorder, _ := ctx.Get(requestRecorderKey).(*RequestRecorder)
+		if request := recordedRequest(ctx); recorder != nil && request != nil {
+			recorder.setResponse(request, ctx.Response().Status, ctx.Response().Header().Clone(), capture.body.Bytes(), time.Since(started))
+		}
+		return nil
+	}
+}
+
+type responseCapture struct {
+	http.ResponseWriter
+	ctx  echo.Context
+	body bytes.Buffer
+}
+
+// Write buffers only once the handler bound a recorder, which it does before
+// writing anything.
+func (c *responseCapture) Write(b []byte) (int, error) {
+	if recordedRequest(c.ctx) != nil {
+		c.body.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *responseCapture) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (c *responseCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("aduket: response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+func recordedRequest(ctx echo.Context) *RecordedRequest {
+	request, _ := ctx.Get(recordedRequestKey).(*RecordedRequest)
+	return request
+}
+
+func (r *RequestRecorder) markRequestReceived() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.isRequestReceived = true
+}
+
+func (r *RequestRecorder) addPreflight(preflight *PreflightRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Preflights = append(r.Preflights, preflight)
+}
+
+func (r *RequestRecorder) addRedirectHop(hop *RedirectHop) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.RedirectHops = append(r.RedirectHops, hop)
+}
+
+// RequestsBySession groups recorded requests by their session id. Requests
+// served without a SessionStore are grouped under the empty id.
+func (r *RequestRecorder) RequestsBySession() map[string][]*RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := make(map[string][]*RecordedRequest)
+	for _, request := range r.Requests {
+		sessions[request.SessionID] = append(sessions[request.SessionID], request)
+	}
+	return sessions
+}
+
+func (r *RequestRecorder) recordPage(page, pageCount int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.PagesServed = append(r.PagesServed, page)
+	r.pageCount = pageCount
+}
+
+// Reset forgets every request recorded so far.
+func (r *RequestRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	*r = RequestRecorder{Params: make(map[string]string), mu: r.mu}
 }
@@ -0,0 +1,373 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	resourceIDField    = "id"
+	resourceIDParam    = "id"
+	resourceOffsetKey  = "offset"
+	resourceLimitKey   = "limit"
+	resourceSortKey    = "sort"
+	resourceOrderKey   = "order"
+	resourceCacheKey   = "_"
+	resourceTotalCount = "X-Total-Count"
+)
+
+// ResourceCollection is an in-memory JSON collection served with list, get,
+// create, update, patch and delete semantics. Items are kept in insertion
+// order and identified by their "id" field. Lists are filtered by every query
+// parameter except offset, limit, sort, order and the "_" cache buster, so a
+// parameter naming a field no item has matches nothing.
+type ResourceCollection struct {
+	mu     sync.Mutex
+	items  []map[string]interface{}
+	nextID int
+}
+
+func NewResourceCollection(seed ...interface{}) *ResourceCollection {
+	collection := &ResourceCollection{nextID: 1}
+	collection.Seed(seed...)
+	return collection
+}
+
+func NewResourceServer(path string, seed ...interface{}) (*httptest.Server, *ResourceCollection, *RequestRecorder) {
+	collection := NewResourceCollection(seed...)
+	route := Route{HttpMethod: echo.GET, Path: path}
+
+	e := createEcho()
+	requestRecorder := registerRoutes(e, map[Route]responseRule{route: createResponseRule([]ResponseRuleOption{Resource(collection)})}, nil)
+
+	return startServer(e), collection, requestRecorder[route]
+}
+
+func registerResourceRoutes(e *echo.Echo, path string, handler echo.HandlerFunc) {
+	itemPath := strings.TrimSuffix(path, "/") + "/:" + resourceIDParam
+
+	e.GET(path, handler)
+	e.POST(path, handler)
+	e.GET(itemPath, handler)
+	e.PUT(itemPath, handler)
+	e.PATCH(itemPath, handler)
+	e.DELETE(itemPath, handler)
+}
+
+// Seed adds items to the collection. Items without an id get a generated one
+// and duplicate ids panic.
+func (c *ResourceCollection) Seed(items ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range items {
+		entity, err := toResourceEntity(item)
+		if err != nil {
+			panic(fmt.Sprintf("aduket: resource seed could not be converted to a JSON object: %v", err))
+		}
+		if id, ok := entity[resourceIDField]; ok && c.indexOf(resourceIDString(id)) >= 0 {
+			panic(fmt.Sprintf("aduket: resource seed has duplicate id %v", id))
+		}
+		c.insert(entity)
+	}
+}
+
+func (c *ResourceCollection) Get(id interface{}) (map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index := c.indexOf(resourceIDString(id))
+	if index < 0 {
+		return nil, false
+	}
+	return copyResourceEntity(c.items[index]), true
+}
+
+// Decode unmarshals the item with the given id into v.
+func (c *ResourceCollection) Decode(id interface{}, v interface{}) error {
+	entity, ok := c.Get(id)
+	if !ok {
+		return fmt.Errorf("aduket: resource %v not found", id)
+	}
+
+	b, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (c *ResourceCollection) All() []map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items := make([]map[string]interface{}, len(c.items))
+	for i, item := range c.items {
+		items[i] = copyResourceEntity(item)
+	}
+	return items
+}
+
+func (c *ResourceCollection) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+func (c *ResourceCollection) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = nil
+	c.nextID = 1
+}
+
+func (c *ResourceCollection) respond(ctx echo.Context, requestRecorder *RequestRecorder) (int, interface{}) {
+	id := ctx.Param(resourceIDParam)
+	method := ctx.Request().Method
+
+	if id == "" {
+		switch method {
+		case http.MethodGet:
+			return c.list(ctx)
+		case http.MethodPost:
+			return c.create(ctx)
+		}
+		return http.StatusMethodNotAllowed, nil
+	}
+
+	switch method {
+	case http.MethodGet:
+		return c.get(id)
+	case http.MethodPut:
+		return c.update(ctx, id, false)
+	case http.MethodPatch:
+		return c.update(ctx, id, true)
+	case http.MethodDelete:
+		return c.delete(id)
+	}
+	return http.StatusMethodNotAllowed, nil
+}
+
+func (c *ResourceCollection) list(ctx echo.Context) (int, interface{}) {
+	queryParams := ctx.QueryParams()
+
+	filter := resourceFilter(queryParams)
+	c.mu.Lock()
+	matches := []map[string]interface{}{}
+	for _, item := range c.items {
+		if matchesResourceFilter(item, filter) {
+			matches = append(matches, copyResourceEntity(item))
+		}
+	}
+	c.mu.Unlock()
+
+	ctx.Response().Header().Set(resourceTotalCount, strconv.Itoa(len(matches)))
+
+	offset, _ := strconv.Atoi(queryParams.Get(resourceOffsetKey))
+	if offset < 0 || offset > len(matches) {
+		offset = len(matches)
+	}
+	end := len(matches)
+	if limit, err := strconv.Atoi(queryParams.Get(resourceLimitKey)); err == nil && limit >= 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	return http.StatusOK, matches[offset:end]
+}
+
+func (c *ResourceCollection) get(id string) (int, interface{}) {
+	entity, ok := c.Get(id)
+	if !ok {
+		return http.StatusNotFound, resourceError("not found")
+	}
+	return http.StatusOK, entity
+}
+
+func (c *ResourceCollection) create(ctx echo.Context) (int, interface{}) {
+	entity, err := readResourceEntity(ctx)
+	if err != nil {
+		return http.StatusBadRequest, resourceError(err.Error())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if id, ok := entity[resourceIDField]; ok && c.indexOf(resourceIDString(id)) >= 0 {
+		return http.StatusConflict, resourceError("already exists")
+	}
+	c.insert(entity)
+
+	ctx.Response().Header().Set(echo.HeaderLocation, strings.TrimSuffix(ctx.Request().URL.Path, "/")+"/"+resourceIDString(entity[resourceIDField]))
+	return http.StatusCreated, copyResourceEntity(entity)
+}
+
+func (c *ResourceCollection) update(ctx echo.Context, id string, merge bool) (int, interface{}) {
+	entity, err := readResourceEntity(ctx)
+	if err != nil {
+		return http.StatusBadRequest, resourceError(err.Error())
+	}
+	if bodyID, ok := entity[resourceIDField]; ok && resourceIDString(bodyID) != id {
+		return http.StatusConflict, resourceError("id does not match")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index := c.indexOf(id)
+	if index < 0 {
+		return http.StatusNotFound, resourceError("not found")
+	}
+
+	if merge {
+		for key, value := range entity {
+			c.items[index][key] = value
+		}
+	} else {
+		entity[resourceIDField] = c.items[index][resourceIDField]
+		c.items[index] = entity
+	}
+
+	return http.StatusOK, copyResourceEntity(c.items[index])
+}
+
+func (c *ResourceCollection) delete(id string) (int, interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index := c.indexOf(id)
+	if index < 0 {
+		return http.StatusNotFound, resourceError("not found")
+	}
+	c.items = append(c.items[:index], c.items[index+1:]...)
+
+	return http.StatusNoContent, nil
+}
+
+func (c *ResourceCollection) insert(entity map[string]interface{}) {
+	if _, ok := entity[resourceIDField]; !ok {
+		for c.indexOf(strconv.Itoa(c.nextID)) >= 0 {
+			c.nextID++
+		}
+		entity[resourceIDField] = c.nextID
+	}
+	if n, err := strconv.Atoi(resourceIDString(entity[resourceIDField])); err == nil && n >= c.nextID {
+		c.nextID = n + 1
+	}
+
+	c.items = append(c.items, entity)
+}
+
+func (c *ResourceCollection) indexOf(id string) int {
+	for i, item := range c.items {
+		if resourceIDString(item[resourceIDField]) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func readResourceEntity(ctx echo.Context) (map[string]interface{}, error) {
+	body, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return nil, err
+	}
+
+	entity := map[string]interface{}{}
+	if err := json.Unmarshal(body, &entity); err != nil {
+		return nil, fmt.Errorf("body is not a JSON object")
+	}
+	return entity, nil
+}
+
+func toResourceEntity(item interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	entity := map[string]interface{}{}
+	err = json.Unmarshal(b, &entity)
+	return entity, err
+}
+
+func copyResourceEntity(entity map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(entity))
+	for key, value := range entity {
+		copied[key] = value
+	}
+	return copied
+}
+
+// resourceFilter drops the query parameters that don't filter.
+func resourceFilter(queryParams map[string][]string) map[string][]string {
+	filter := make(map[string][]string, len(queryParams))
+	for key, values := range queryParams {
+		switch key {
+		case resourceOffsetKey, resourceLimitKey, resourceSortKey, resourceOrderKey, resourceCacheKey:
+			continue
+		}
+		filter[key] = values
+	}
+	return filter
+}
+
+func matchesResourceFilter(entity map[string]interface{}, filter map[string][]string) bool {
+	for key, values := range filter {
+		value, ok := entity[key]
+		if !ok {
+			return false
+		}
+		if !containsString(values, resourceIDString(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func resourceIDString(id interface{}) string {
+	switch v := id.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(id)
+}
+
+func resourceError(message string) map[string]string {
+	return map[string]string{"error": message}
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
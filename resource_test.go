@@ -0,0 +1,127 @@
+package aduket
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceServerCRUD(t *testing.T) {
+	server, users, requestRecorder := NewResourceServer("/users", User{ID: 1, Name: "ken"}, User{ID: 2, Name: "ryu"})
+	defer server.Close()
+
+	response := doJSON(t, http.MethodPost, server.URL+"/users", map[string]string{"name": "chun-li"})
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "/users/3", response.Header.Get("Location"))
+
+	created := User{}
+	json.NewDecoder(response.Body).Decode(&created)
+	assert.Equal(t, User{ID: 3, Name: "chun-li"}, created)
+	requestRecorder.AssertJSONBodyEqual(t, map[string]string{"name": "chun-li"})
+
+	response = doJSON(t, http.MethodGet, server.URL+"/users/3", nil)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	fetched := User{}
+	json.NewDecoder(response.Body).Decode(&fetched)
+	assert.Equal(t, created, fetched)
+
+	response = doJSON(t, http.MethodPost, server.URL+"/users", User{ID: 1, Name: "ken"})
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	response = doJSON(t, http.MethodPatch, server.URL+"/users/1", map[string]string{"name": "akuma"})
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response = doJSON(t, http.MethodPut, server.URL+"/users/2", map[string]string{"name": "guile"})
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	patched := User{}
+	assert.Nil(t, users.Decode(1, &patched))
+	assert.Equal(t, User{ID: 1, Name: "akuma"}, patched)
+	replaced := User{}
+	assert.Nil(t, users.Decode(2, &replaced))
+	assert.Equal(t, User{ID: 2, Name: "guile"}, replaced)
+
+	response = doJSON(t, http.MethodDelete, server.URL+"/users/2", nil)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	response = doJSON(t, http.MethodDelete, server.URL+"/users/2", nil)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	response = doJSON(t, http.MethodGet, server.URL+"/users/2", nil)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	assert.Equal(t, 2, users.Len())
+	assert.Equal(t, http.StatusNotFound, requestRecorder.Requests[len(requestRecorder.Requests)-1].StatusCode)
+}
+
+func TestResourceListFilterAndPagination(t *testing.T) {
+	users := NewResourceCollection()
+	route := Route{HttpMethod: http.MethodGet, Path: "/users"}
+	server, _ := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		route: {Resource(users)},
+	})
+	defer server.Close()
+
+	users.Seed(
+		map[string]string{"name": "ken"},
+		map[string]string{"name": "ryu"},
+		map[string]string{"name": "ken"},
+		map[string]string{"name": "ken"},
+	)
+	assert.Panics(t, func() { users.Seed(User{ID: 1, Name: "akuma"}) })
+
+	response := doJSON(t, http.MethodGet, server.URL+"/users?name=ken&offset=1&limit=1", nil)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "3", response.Header.Get("X-Total-Count"))
+
+	page := []User{}
+	json.NewDecoder(response.Body).Decode(&page)
+	assert.Equal(t, []User{{ID: 3, Name: "ken"}}, page)
+
+	response = doJSON(t, http.MethodGet, server.URL+"/users?name=ken&sort=name&_=1602000000", nil)
+	assert.Equal(t, "3", response.Header.Get("X-Total-Count"))
+
+	for _, query := range []string{"name=akuma", "nme=ken", "deleted=true"} {
+		response = doJSON(t, http.MethodGet, server.URL+"/users?"+query, nil)
+		assert.Equal(t, "0", response.Header.Get("X-Total-Count"), query)
+	}
+
+	users.Reset()
+	response = doJSON(t, http.MethodGet, server.URL+"/users", nil)
+	page = nil
+	json.NewDecoder(response.Body).Decode(&page)
+	assert.Equal(t, []User{}, page)
+}
+
+func TestResourceListFilterAfterDelete(t *testing.T) {
+	server, users, _ := NewResourceServer("/users",
+		map[string]string{"name": "ken", "role": "admin"},
+		map[string]string{"name": "ryu", "role": "user"},
+	)
+	defer server.Close()
+
+	response := doJSON(t, http.MethodDelete, server.URL+"/users/1", nil)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Equal(t, 1, users.Len())
+
+	response = doJSON(t, http.MethodGet, server.URL+"/users?role=admin", nil)
+	page := []map[string]interface{}{}
+	json.NewDecoder(response.Body).Decode(&page)
+	assert.Empty(t, page)
+	assert.Equal(t, "0", response.Header.Get("X-Total-Count"))
+}
+
+func doJSON(t *testing.T, method, url string, body interface{}) *http.Response {
+	var request *http.Request
+	if body == nil {
+		request, _ = http.NewRequest(method, url, http.NoBody)
+	} else {
+		b, _ := json.Marshal(body)
+		request, _ = http.NewRequest(method, url, bytes.NewReader(b))
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	return response
+}
@@ -51,12 +51,119 @@ func Header(header http.Header) ResponseRuleOption {
 	}
 }
 
+// Timeout delays the response by duration, or until the client gives up, in
+// which case nothing is sent.
 func Timeout(duration time.Duration) ResponseRuleOption {
 	return func(r *responseRule) {
 		r.timeout = duration
 	}
 }
 
+func RequireBasicAuth(username, password string) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.authenticator = basicAuthenticator{username: username, password: password}
+	}
+}
+
+func RequireBearer(tokens ...string) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.authenticator = bearerAuthenticator{tokens: tokens}
+	}
+}
+
+func RequireAPIKey(header string, keys ...string) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.authenticator = apiKeyAuthenticator{header: header, keys: keys}
+	}
+}
+
+func RequireDigestAuth(realm, username, password string) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.authenticator = newDigestAuthenticator(realm, username, password)
+	}
+}
+
+func RequireOAuth2(provider *OAuth2Provider) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.authenticator = oauth2Authenticator{provider: provider}
+	}
+}
+
+func CORS(policy CORSPolicy) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.cors = &policy
+	}
+}
+
+// Resource serves collection with REST semantics on the route path and
+// path/:id. The route's HttpMethod is ignored.
+func Resource(collection *ResourceCollection) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.responder = collection
+	}
+}
+
+// Paginate serves items, which must marshal to a JSON array, pageSize at a
+// time in the given style.
+func Paginate(items interface{}, pageSize int, style PaginationStyle) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.responder = newPagination(items, pageSize, style)
+	}
+}
+
+// Redirect answers with statusCode and a Location header. location may be a
+// path on the same server or an absolute URL, e.g. another aduket server.
+func Redirect(statusCode int, location string) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.redirect = &redirectRule{statusCode: statusCode, location: location}
+	}
+}
+
+// RedirectChain redirects the route to itself n times before serving the
+// configured response.
+func RedirectChain(n int) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.redirect = &redirectRule{statusCode: http.StatusFound, chain: n}
+	}
+}
+
+// RedirectLoop redirects the route to itself forever.
+func RedirectLoop() ResponseRuleOption {
+	return func(r *responseRule) {
+		r.redirect = &redirectRule{statusCode: http.StatusFound, loop: true}
+	}
+}
+
+func SetCookie(cookie *http.Cookie) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.cookies = append(r.cookies, cookie)
+	}
+}
+
+// Sessions issues a session cookie from store to clients that don't present
+// one and tags every recorded request with its session id.
+func Sessions(store *SessionStore) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.sessions = store
+	}
+}
+
+// ExpectTimes makes servers built with New fail the test unless the route is
+// called exactly n times.
+func ExpectTimes(n int) ResponseRuleOption {
+	return func(r *responseRule) {
+		r.expectation = &callExpectation{min: n, max: n}
+	}
+}
+
+// ExpectCalled makes servers built with New fail the test unless the route is
+// called at least once.
+func ExpectCalled() ResponseRuleOption {
+	return func(r *responseRule) {
+		r.expectation = &callExpectation{min: 1, max: -1}
+	}
+}
+
 func jsonToResponseBody(j interface{}) responseBody {
 	jsonBytes, _ := json.Marshal(j)
 	return jsonBytes
@@ -17,8 +17,13 @@
 package aduket
 
 import (
+	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/labstack/echo"
@@ -26,9 +31,32 @@ import (
 
 type responseBody []byte
 
+// Route identifies a stub. Routes with a Host only answer requests for that
+// host name, whatever the port; routes without one answer every host.
 type Route struct {
 	HttpMethod string
 	Path       string
+	Host       string
+}
+
+func (r Route) String() string {
+	return r.HttpMethod + " " + r.Host + r.Path
+}
+
+// routePathRegex turns /users/:id into /users/[^/]+.
+func routePathRegex(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = "[^/]+"
+		case segment == "*":
+			segments[i] = ".*"
+		default:
+			segments[i] = regexp.QuoteMeta(segment)
+		}
+	}
+	return strings.Join(segments, "/")
 }
 
 type responseRule struct {
@@ -37,35 +65,223 @@ type responseRule struct {
 	statusCode        int
 	timeout           time.Duration
 	sendCorruptedBody bool
+	authenticator     authenticator
+	cors              *CORSPolicy
+	cookies           []*http.Cookie
+	sessions          *SessionStore
+	redirect          *redirectRule
+	responder         responder
+	expectation       *callExpectation
+	sequence          *responseSequence
+	validator         requestValidator
+	providerState     string
+}
+
+type responseSequence struct {
+	rules []responseRule
+
+	mu    sync.Mutex
+	calls int
+}
+
+// responder builds the response dynamically for rules whose body depends on
+// the incoming request. Bodies are sent as JSON unless they are a rawResponse.
+type responder interface {
+	respond(ctx echo.Context, requestRecorder *RequestRecorder) (int, interface{})
+}
+
+// requestValidator checks requests against a contract such as an OpenAPI
+// operation and returns the violations found.
+type requestValidator interface {
+	validate(request *http.Request, params map[string]string) []string
+}
+
+type rawResponse struct {
+	contentType string
+	body        []byte
+}
+
+func NewMultiRouteServer(routeResponseOptions map[Route][]ResponseRuleOption, serverOptions ...ServerOption) (*httptest.Server, map[Route]*RequestRecorder) {
+	config := createServerConfig(serverOptions)
+	rules := withDefaultCORS(createRouteResponseRules(routeResponseOptions), config.cors)
+	handler, requestRecorder := newRouteHandler(rules, nil, config.redirects, nil)
+
+	return startServer(handler, serverOptions...), requestRecorder
+}
+
+func NewServer(httpMethod, path string, responseRuleOptions ...ResponseRuleOption) (*httptest.Server, *RequestRecorder) {
+	return NewServerWithOptions(httpMethod, path, responseRuleOptions)
+}
+
+// NewServerWithOptions is NewServer with server level options such as the
+// listen address or TLS, taken last as NewMultiRouteServer does.
+func NewServerWithOptions(httpMethod, path string, responseRuleOptions []ResponseRuleOption, serverOptions ...ServerOption) (*httptest.Server, *RequestRecorder) {
+	route := Route{HttpMethod: httpMethod, Path: path}
+
+	config := createServerConfig(serverOptions)
+	rules := withDefaultCORS(map[Route]responseRule{route: createResponseRule(responseRuleOptions)}, config.cors)
+	handler, requestRecorder := newRouteHandler(rules, nil, config.redirects, nil)
+
+	return startServer(handler, serverOptions...), requestRecorder[route]
 }
 
-func NewMultiRouteServer(routeResponseOptions map[Route][]ResponseRuleOption) (*httptest.Server, map[Route]*RequestRecorder) {
-	requestRecorder := make(map[Route]*RequestRecorder)
-	e := createEcho()
+// newRouteHandler serves the rules with one echo per host bound by a route,
+// each also serving the routes without a host, and a fallback echo for the
+// other hosts. A nil journal gets a fresh one; setup, when not nil, is
+// applied to every echo.
+func newRouteHandler(routeResponseRules map[Route]responseRule, requestRecorder map[Route]*RequestRecorder, journal *RedirectJournal, setup func(*echo.Echo)) (http.Handler, map[Route]*RequestRecorder) {
+	if journal == nil {
+		journal = NewRedirectJournal()
+	}
 
-	routeResponseRules := createRouteResponseRules(routeResponseOptions)
-	for route, responseRule := range routeResponseRules {
-		routeRequestRecorder := NewRequestRecorder()
-		requestRecorder[route] = routeRequestRecorder
+	anyHostRules := make(map[Route]responseRule)
+	hostRules := make(map[string]map[Route]responseRule)
+	for route, rule := range routeResponseRules {
+		if route.Host == "" {
+			anyHostRules[route] = rule
+			continue
+		}
+		host := normalizeHost(route.Host)
+		if hostRules[host] == nil {
+			hostRules[host] = make(map[Route]responseRule)
+		}
+		hostRules[host][route] = rule
+	}
+
+	newEcho := func(rules map[Route]responseRule) *echo.Echo {
+		e := createEchoWithRedirects(journal)
+		echoRules := make(map[Route]responseRule, len(anyHostRules)+len(rules))
+		for route, rule := range anyHostRules {
+			echoRules[route] = rule
+		}
+		for route, rule := range rules {
+			echoRules[route] = rule
+		}
+		requestRecorder = registerRoutes(e, echoRules, requestRecorder)
+		if setup != nil {
+			setup(e)
+		}
+		return e
+	}
+
+	fallback := newEcho(nil)
+	if len(hostRules) == 0 {
+		return fallback, requestRecorder
+	}
+
+	router := &hostRouter{hosts: make(map[string]http.Handler, len(hostRules)), fallback: fallback}
+	for host, rules := range hostRules {
+		router.hosts[host] = newEcho(rules)
+	}
+	return router, requestRecorder
+}
+
+// registerRoutes adds a handler per rule, reusing the recorders already in
+// requestRecorder and creating the missing ones. Routes with a host are
+// registered last so they win over the same route without one.
+func registerRoutes(e *echo.Echo, routeResponseRules map[Route]responseRule, requestRecorder map[Route]*RequestRecorder) map[Route]*RequestRecorder {
+	if requestRecorder == nil {
+		requestRecorder = make(map[Route]*RequestRecorder)
+	}
+	for _, route := range sortedRuleRoutes(routeResponseRules) {
+		responseRule := routeResponseRules[route]
+		routeRequestRecorder, ok := requestRecorder[route]
+		if !ok {
+			routeRequestRecorder = NewRequestRecorder()
+			requestRecorder[route] = routeRequestRecorder
+		}
+
+		if _, ok := responseRule.responder.(*ResourceCollection); ok {
+			registerResourceRoutes(e, route.Path, spyHandler(routeRequestRecorder, responseRule))
+			continue
+		}
 		e.Add(route.HttpMethod, route.Path, spyHandler(routeRequestRecorder, responseRule))
 	}
 
-	return httptest.NewServer(e), requestRecorder
+	registerPreflightHandlers(e, routeResponseRules, requestRecorder)
+
+	return requestRecorder
 }
 
-func NewServer(httpMethod, path string, responseRuleOptions ...ResponseRuleOption) (*httptest.Server, *RequestRecorder) {
-	requestRecorder := NewRequestRecorder()
+// sortedRuleRoutes returns the routes of rules with the ones without a host
+// first.
+func sortedRuleRoutes(routeResponseRules map[Route]responseRule) []Route {
+	routes := make([]Route, 0, len(routeResponseRules))
+	for route := range routeResponseRules {
+		routes = append(routes, route)
+	}
+	sortRoutes(routes)
+	return routes
+}
+
+// startServer panics when the listener can't be opened, like the other
+// configuration errors of the constructors. Servers started here have no
+// routes to manage, so AdminAPI is rejected.
+func startServer(handler http.Handler, serverOptions ...ServerOption) *httptest.Server {
+	config := createServerConfig(serverOptions)
+	if config.adminAPI {
+		panic(errAdminAPIUnsupported)
+	}
 
-	e := createEcho()
-	responseRule := createResponseRule(responseRuleOptions)
+	server, err := startConfiguredServer(handler, config)
+	if err != nil {
+		panic(err)
+	}
+	return server
+}
+
+func startConfiguredServer(handler http.Handler, config serverConfig) (*httptest.Server, error) {
+	server := newUnstartedServer(handler)
+
+	listener, err := config.listen()
+	if err != nil {
+		server.Listener.Close()
+		return nil, err
+	}
+	if listener != nil {
+		server.Listener.Close()
+		server.Listener = listener
+	}
+
+	if config.h2c {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+		server.Config.Protocols = protocols
+	}
+
+	if config.tlsOptions != nil {
+		server.TLS = config.tlsOptions.config()
+		server.EnableHTTP2 = config.tlsOptions.EnableHTTP2
+		// Handshake failures are expected when testing faults; keep them out
+		// of the test output.
+		server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+
+	if server.Listener.Addr().Network() == "unix" {
+		server.URL = UnixSocketURL
+		if config.tlsOptions != nil {
+			server.URL = UnixSocketTLSURL
+		}
+	}
 
-	e.Add(httpMethod, path, spyHandler(requestRecorder, responseRule))
-	return httptest.NewServer(e), requestRecorder
+	return server, nil
 }
 
 func createEcho() *echo.Echo {
+	return createEchoWithRedirects(NewRedirectJournal())
+}
+
+// createEchoWithRedirects lets echo instances of one server, such as the ones
+// per host, share the redirects they issued.
+func createEchoWithRedirects(journal *RedirectJournal) *echo.Echo {
 	e := echo.New()
 	e.Binder = &RequestRecorderBinder{}
+	e.Use(captureResponse)
+	e.Use(journal.middleware)
 	return e
 }
 
@@ -97,9 +313,41 @@ func (r *RequestRecorderBinder) Bind(requestRecorder interface{}, ctx echo.Conte
 }
 
 func spyHandler(requestRecorder *RequestRecorder, res responseRule) echo.HandlerFunc {
+	if res.sequence != nil && len(res.sequence.rules) != 0 {
+		return res.sequence.handler(requestRecorder)
+	}
+
 	return func(ctx echo.Context) error {
-		requestRecorder.isRequestReceived = true
+		requestRecorder.markRequestReceived()
+
+		if res.cors != nil {
+			res.cors.applyActualRequestHeaders(ctx)
+		}
+
+		if err := ctx.Bind(requestRecorder); err != nil {
+			return err
+		}
+		journal := contextRedirectJournal(ctx)
+		if journal != nil {
+			if hop, ok := journal.follow(ctx.Request()); ok {
+				requestRecorder.addRedirectHop(hop)
+			}
+		}
+		if res.sessions != nil {
+			res.sessions.attach(ctx, recordedRequest(ctx))
+		}
+		if res.validator != nil {
+			request := recordedRequest(ctx)
+			requestRecorder.addSpecViolations(request, res.validator.validate(ctx.Request(), request.Params))
+		}
+
+		if res.authenticator != nil && !res.authenticator.authenticate(ctx.Request()) {
+			requestRecorder.setResponseStatus(ctx, http.StatusUnauthorized)
+			ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, res.authenticator.challenge(ctx.Request()))
+			return ctx.NoContent(http.StatusUnauthorized)
+		}
 
+		// Faults only reach clients that passed authentication.
 		if res.sendCorruptedBody {
 			// Forces client to read empty buffer and BOOM!
 			ctx.Response().Header().Set("Content-Length", "1")
@@ -107,11 +355,13 @@ func spyHandler(requestRecorder *RequestRecorder, res responseRule) echo.Handler
 		}
 
 		if res.timeout != 0 {
-			time.Sleep(res.timeout)
-		}
-
-		if err := ctx.Bind(requestRecorder); err != nil {
-			return err
+			timer := time.NewTimer(res.timeout)
+			select {
+			case <-timer.C:
+			case <-ctx.Request().Context().Done():
+				timer.Stop()
+				return nil
+			}
 		}
 
 		for key, values := range res.header {
@@ -119,6 +369,33 @@ func spyHandler(requestRecorder *RequestRecorder, res responseRule) echo.Handler
 				ctx.Response().Header().Add(key, value)
 			}
 		}
+		for _, cookie := range res.cookies {
+			ctx.SetCookie(cookie)
+		}
+
+		if res.redirect != nil {
+			if location, ok := res.redirect.locationFor(ctx.Request()); ok {
+				if journal != nil {
+					journal.issue(ctx.Request(), location, res.redirect.statusCode)
+				}
+				requestRecorder.setResponseStatus(ctx, res.redirect.statusCode)
+				ctx.Response().Header().Set(echo.HeaderLocation, location)
+				return ctx.NoContent(res.redirect.statusCode)
+			}
+		}
+
+		if res.responder != nil {
+			statusCode, body := res.responder.respond(ctx, requestRecorder)
+			requestRecorder.setResponseStatus(ctx, statusCode)
+			if body == nil {
+				return ctx.NoContent(statusCode)
+			}
+			if raw, ok := body.(rawResponse); ok {
+				return ctx.Blob(statusCode, raw.contentType, raw.body)
+			}
+			return ctx.JSON(statusCode, body)
+		}
+		requestRecorder.setResponseStatus(ctx, res.statusCode)
 
 		if res.body == nil {
 			return ctx.NoContent(res.statusCode)
@@ -133,3 +410,21 @@ func spyHandler(requestRecorder *RequestRecorder, res responseRule) echo.Handler
 		return nil
 	}
 }
+
+func (s *responseSequence) handler(requestRecorder *RequestRecorder) echo.HandlerFunc {
+	handlers := make([]echo.HandlerFunc, len(s.rules))
+	for i, rule := range s.rules {
+		handlers[i] = spyHandler(requestRecorder, rule)
+	}
+
+	return func(ctx echo.Context) error {
+		s.mu.Lock()
+		index := s.calls
+		if index < len(handlers)-1 {
+			s.calls++
+		}
+		s.mu.Unlock()
+
+		return handlers[index](ctx)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/xml"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/labstack/echo"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -117,7 +119,7 @@ func TestServerRequestRecorderBody(t *testing.T) {
 		expectedBody   interface{}
 	}{
 		{
-			route: Route{http.MethodPost, "/user"},
+			route: Route{HttpMethod: http.MethodPost, Path: "/user"},
 			request: func(url string) *http.Request {
 				return newJSONRequest(http.MethodPost, url+"/user", User{ID: 133, Name: "Ken"})
 			},
@@ -125,7 +127,7 @@ func TestServerRequestRecorderBody(t *testing.T) {
 			expectedBody:   User{ID: 133, Name: "Ken"},
 		},
 		{
-			route: Route{http.MethodPost, "/book"},
+			route: Route{HttpMethod: http.MethodPost, Path: "/book"},
 			request: func(url string) *http.Request {
 				return newXMLRequest(http.MethodPost, url+"/book", Book{ISBN: "123-321-123", Name: "SICP"})
 			},
@@ -159,6 +161,24 @@ func TestServerWithTimeout(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestServerResponseSequence(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodGet, "/job", sequenceOf(
+		[]ResponseRuleOption{StatusCode(http.StatusAccepted), StringBody("pending")},
+		[]ResponseRuleOption{StatusCode(http.StatusOK), StringBody("done")},
+	))
+	defer server.Close()
+
+	for _, expected := range []ExpectedResponse{
+		{statusCode: http.StatusAccepted, body: []byte("pending")},
+		{statusCode: http.StatusOK, body: []byte("done")},
+		{statusCode: http.StatusOK, body: []byte("done")},
+	} {
+		testRouteResponse(t, server.URL, Route{HttpMethod: http.MethodGet, Path: "/job"}, expected)
+	}
+	assert.Len(t, requestRecorder.Requests, 3)
+	assert.Equal(t, []byte("pending"), []byte(requestRecorder.Requests[0].ResponseBody))
+}
+
 func TestMultiRouteServerResponse(t *testing.T) {
 	tests := []struct {
 		routeResponseRuleOptions map[Route][]ResponseRuleOption
@@ -166,24 +186,24 @@ func TestMultiRouteServerResponse(t *testing.T) {
 	}{
 		{
 			routeResponseRuleOptions: map[Route][]ResponseRuleOption{
-				{http.MethodGet, "/user"}: {
+				{HttpMethod: http.MethodGet, Path: "/user"}: {
 					StatusCode(http.StatusOK),
 					JSONBody(User{ID: 123, Name: "kalt"}),
 					Header(http.Header{"Content-Type": []string{"application/json"}}),
 				},
-				{http.MethodGet, "/book"}: {
+				{HttpMethod: http.MethodGet, Path: "/book"}: {
 					StatusCode(http.StatusTeapot),
 					Header(http.Header{"Content-Type": []string{"application/xml"}}),
 					XMLBody(Book{ISBN: "9780262510875", Name: "Structure and Interpretation of Computer Programs"}),
 				},
 			},
 			expectedRouteResponses: map[Route]ExpectedResponse{
-				{http.MethodGet, "/user"}: {
+				{HttpMethod: http.MethodGet, Path: "/user"}: {
 					statusCode: http.StatusOK,
 					header:     http.Header{"Content-Type": []string{"application/json"}},
 					body:       jsonMarshal(User{ID: 123, Name: "kalt"}),
 				},
-				{http.MethodGet, "/book"}: {
+				{HttpMethod: http.MethodGet, Path: "/book"}: {
 					statusCode: http.StatusTeapot,
 					header:     http.Header{"Content-Type": []string{"application/xml"}},
 					body:       xmlMarshal(Book{ISBN: "9780262510875", Name: "Structure and Interpretation of Computer Programs"}),
@@ -212,8 +232,8 @@ func TestMultiRouteServerRequestRecorderBody(t *testing.T) {
 	}{
 		{
 			routes: []Route{
-				{http.MethodPost, "/user"},
-				{http.MethodPost, "/book"},
+				{HttpMethod: http.MethodPost, Path: "/user"},
+				{HttpMethod: http.MethodPost, Path: "/book"},
 			},
 			requests: func(url string) []*http.Request {
 				reqs := []*http.Request{
@@ -223,12 +243,12 @@ func TestMultiRouteServerRequestRecorderBody(t *testing.T) {
 				return reqs
 			},
 			routeBodyAssertFunc: map[Route]bodyAssertFunc{
-				{http.MethodPost, "/user"}: isJSONEqual,
-				{http.MethodPost, "/book"}: isXMLEqual,
+				{HttpMethod: http.MethodPost, Path: "/user"}: isJSONEqual,
+				{HttpMethod: http.MethodPost, Path: "/book"}: isXMLEqual,
 			},
 			expectedRouteRequestRecorderBodies: map[Route]interface{}{
-				{http.MethodPost, "/user"}: User{ID: 1222, Name: "nonono"},
-				{http.MethodPost, "/book"}: Book{ISBN: "123-321-123", Name: "SICP"},
+				{HttpMethod: http.MethodPost, Path: "/user"}: User{ID: 1222, Name: "nonono"},
+				{HttpMethod: http.MethodPost, Path: "/book"}: Book{ISBN: "123-321-123", Name: "SICP"},
 			},
 		},
 	}
@@ -287,3 +307,18 @@ func xmlMarshal(x interface{}) []byte {
 	m, _ := xml.Marshal(x)
 	return m
 }
+
+func TestCaptureResponseSkipsUnrecordedRequests(t *testing.T) {
+	var capture *responseCapture
+	e := createEcho()
+	e.GET("/health", func(ctx echo.Context) error {
+		capture = ctx.Response().Writer.(*responseCapture)
+		return ctx.String(http.StatusOK, "ok")
+	})
+
+	response := httptest.NewRecorder()
+	e.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	assert.Equal(t, "ok", response.Body.String())
+	assert.Zero(t, capture.body.Len())
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// UnixSocketURL is the URL of servers listening on a Unix domain socket. The
+// host is a placeholder; use UnixSocketClient to reach the socket. Servers
+// with ServeTLS use UnixSocketTLSURL.
+const (
+	UnixSocketURL    = "http://unix"
+	UnixSocketTLSURL = "https://unix"
+)
+
+type serverConfig struct {
+	network    string
+	address    string
+	listener   net.Listener
+	tlsOptions *TLSOptions
+	h2c        bool
+	adminAPI   bool
+	cors       *CORSPolicy
+	redirects  *RedirectJournal
+}
+
+type ServerOption func(*serverConfig)
+
+var errAdminAPIUnsupported = errors.New("aduket: AdminAPI is only supported by NewManagedServer and New")
+
+// ListenAddress binds the server to a fixed host:port instead of a random
+// loopback port.
+func ListenAddress(hostPort string) ServerOption {
+	return func(c *serverConfig) {
+		c.network, c.address = "tcp", hostPort
+	}
+}
+
+func ListenUnix(socketPath string) ServerOption {
+	return func(c *serverConfig) {
+		c.network, c.address = "unix", socketPath
+	}
+}
+
+func ListenIPv6Loopback() ServerOption {
+	return func(c *serverConfig) {
+		c.network, c.address = "tcp6", "[::1]:0"
+	}
+}
+
+// ListenOn serves on a caller supplied listener. The server takes ownership
+// and closes it on Close.
+func ListenOn(listener net.Listener) ServerOption {
+	return func(c *serverConfig) {
+		c.listener = listener
+	}
+}
+
+func ServeTLS(options TLSOptions) ServerOption {
+	return func(c *serverConfig) {
+		c.tlsOptions = &options
+	}
+}
+
+// ServeH2C accepts cleartext HTTP/2 with prior knowledge alongside HTTP/1.1.
+func ServeH2C() ServerOption {
+	return func(c *serverConfig) {
+		c.h2c = true
+	}
+}
+
+// AdminAPI serves the admin API under AdminPathPrefix so stubs can be managed
+// over HTTP. Only servers built with NewManagedServer or New support it; the
+// other constructors panic when given it.
+func AdminAPI() ServerOption {
+	return func(c *serverConfig) {
+		c.adminAPI = true
+	}
+}
+
+// ServeCORS applies policy to every route without a CORS rule option of its
+// own.
+func ServeCORS(policy CORSPolicy) ServerOption {
+	return func(c *serverConfig) {
+		c.cors = &policy
+	}
+}
+
+// ShareRedirects tracks the redirects of the server in journal. Servers given
+// the same journal record the hops of redirects from one into another.
+func ShareRedirects(journal *RedirectJournal) ServerOption {
+	return func(c *serverConfig) {
+		c.redirects = journal
+	}
+}
+
+// UnixSocketClient returns an *http.Client that sends every request to the
+// socket, whatever the URL host. Pair it with UnixSocketURL.
+func UnixSocketClient(socketPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
+
+func createServerConfig(serverOptions []ServerOption) serverConfig {
+	config := serverConfig{}
+	for _, serverOption := range serverOptions {
+		serverOption(&config)
+	}
+	return config
+}
+
+func (c serverConfig) listen() (net.Listener, error) {
+	if c.listener != nil {
+		return c.listener, nil
+	}
+	if c.network == "" {
+		return nil, nil
+	}
+
+	listener, err := net.Listen(c.network, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("aduket: failed to listen on %s %s: %v", c.network, c.address, err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,105 @@
+package aduket
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerListenAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	server, requestRecorder := NewServerWithOptions(http.MethodGet, "/user", nil, ListenAddress(address))
+	defer server.Close()
+
+	assert.Equal(t, "http://"+address, server.URL)
+
+	_, err = http.Get("http://" + address + "/user")
+	assert.Nil(t, err)
+	assert.Len(t, requestRecorder.Requests, 1)
+}
+
+func TestServerListenUnix(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "aduket.sock")
+	route := Route{HttpMethod: http.MethodGet, Path: "/user"}
+
+	server, requestRecorder := NewMultiRouteServer(
+		map[Route][]ResponseRuleOption{route: {StringBody("Hello")}},
+		ListenUnix(socketPath),
+	)
+	defer server.Close()
+
+	assert.Equal(t, UnixSocketURL, server.URL)
+
+	response, err := UnixSocketClient(socketPath).Get(server.URL + "/user")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Len(t, requestRecorder[route].Requests, 1)
+}
+
+func TestServerListenUnixTLS(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "aduket.sock")
+	ca := NewCertificateAuthority()
+
+	server, requestRecorder := NewMultiRouteServer(
+		map[Route][]ResponseRuleOption{{HttpMethod: http.MethodGet, Path: "/user"}: {}},
+		ListenUnix(socketPath), ServeTLS(TLSOptions{CA: ca}),
+	)
+	defer server.Close()
+
+	assert.Equal(t, UnixSocketTLSURL, server.URL)
+
+	client := UnixSocketClient(socketPath)
+	client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: ca.CertPool(), ServerName: "localhost"}
+	response, err := client.Get(server.URL + "/user")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Len(t, requestRecorder[Route{HttpMethod: http.MethodGet, Path: "/user"}].Requests, 1)
+}
+
+func TestServerListenIPv6Loopback(t *testing.T) {
+	if listener, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skip("IPv6 loopback is not available")
+	} else {
+		listener.Close()
+	}
+
+	server, requestRecorder := NewServerWithOptions(http.MethodGet, "/user", nil, ListenIPv6Loopback())
+	defer server.Close()
+
+	_, err := http.Get(server.URL + "/user")
+	assert.Nil(t, err)
+	assert.Equal(t, "[::1]", server.URL[len("http://"):len("http://[::1]")])
+	assert.Len(t, requestRecorder.Requests, 1)
+}
+
+func TestServerListenOn(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	server, requestRecorder := NewServerWithOptions(http.MethodGet, "/user", nil, ListenOn(listener))
+	defer server.Close()
+
+	assert.Equal(t, "http://"+listener.Addr().String(), server.URL)
+
+	_, err = http.Get(server.URL + "/user")
+	assert.Nil(t, err)
+	assert.Len(t, requestRecorder.Requests, 1)
+}
+
+func TestServerListenAddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	assert.Panics(t, func() {
+		NewServerWithOptions(http.MethodGet, "/user", nil, ListenAddress(listener.Addr().String()))
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+const DefaultSessionCookieName = "aduket_session"
+
+// SessionStore issues session cookies and groups requests by session. Routes
+// sharing a store share their sessions.
+type SessionStore struct {
+	CookieName string
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+	order    []string
+}
+
+type Session struct {
+	ID string
+
+	mu       sync.Mutex
+	values   map[string]interface{}
+	requests []*RecordedRequest
+}
+
+func NewSessionStore() *SessionStore {
+	return &SessionStore{
+		CookieName: DefaultSessionCookieName,
+		sessions:   make(map[string]*Session),
+	}
+}
+
+func (s *SessionStore) Session(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	return session, ok
+}
+
+// Sessions returns every session in the order they were issued.
+func (s *SessionStore) Sessions() []*Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := make([]*Session, len(s.order))
+	for i, id := range s.order {
+		sessions[i] = s.sessions[id]
+	}
+	return sessions
+}
+
+func (s *SessionStore) attach(ctx echo.Context, request *RecordedRequest) {
+	s.mu.Lock()
+	var session *Session
+	if cookie, err := ctx.Cookie(s.CookieName); err == nil {
+		session = s.sessions[cookie.Value]
+	}
+	if session == nil {
+		session = &Session{ID: randomHex(16), values: make(map[string]interface{})}
+		s.sessions[session.ID] = session
+		s.order = append(s.order, session.ID)
+		ctx.SetCookie(&http.Cookie{Name: s.CookieName, Value: session.ID, Path: "/", HttpOnly: true})
+	}
+	s.mu.Unlock()
+
+	if request == nil {
+		return
+	}
+	request.SessionID = session.ID
+
+	session.mu.Lock()
+	session.requests = append(session.requests, request)
+	session.mu.Unlock()
+}
+
+func (s *Session) Get(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.values[key]
+	return value, ok
+}
+
+func (s *Session) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+func (s *Session) Requests() []*RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*RecordedRequest(nil), s.requests...)
+}
@@ -0,0 +1,85 @@
+package aduket
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCookie(t *testing.T) {
+	server, _ := NewServer(http.MethodGet, "/login",
+		SetCookie(&http.Cookie{Name: "token", Value: "123"}),
+		SetCookie(&http.Cookie{Name: "theme", Value: "dark"}),
+	)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/login")
+	assert.Nil(t, err)
+
+	cookies := response.Cookies()
+	assert.Len(t, cookies, 2)
+	assert.Equal(t, "token", cookies[0].Name)
+	assert.Equal(t, "123", cookies[0].Value)
+}
+
+func TestSessions(t *testing.T) {
+	store := NewSessionStore()
+	loginRoute := Route{HttpMethod: http.MethodPost, Path: "/login"}
+	cartRoute := Route{HttpMethod: http.MethodGet, Path: "/cart"}
+
+	server, requestRecorder := NewMultiRouteServer(map[Route][]ResponseRuleOption{
+		loginRoute: {Sessions(store)},
+		cartRoute:  {Sessions(store)},
+	})
+	defer server.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+
+	_, err := client.Post(server.URL+"/login", "text/plain", http.NoBody)
+	assert.Nil(t, err)
+	_, err = client.Get(server.URL + "/cart")
+	assert.Nil(t, err)
+	_, err = http.Get(server.URL + "/cart")
+	assert.Nil(t, err)
+
+	sessions := store.Sessions()
+	assert.Len(t, sessions, 2)
+	assert.Len(t, sessions[0].Requests(), 2)
+	assert.Len(t, sessions[1].Requests(), 1)
+
+	sessions[0].Set("user", "ken")
+	user, ok := sessions[0].Get("user")
+	assert.True(t, ok)
+	assert.Equal(t, "ken", user)
+
+	login := requestRecorder[loginRoute].Requests[0]
+	cart := requestRecorder[cartRoute].Requests
+	assert.Len(t, requestRecorder[cartRoute].RequestsBySession(), 2)
+
+	tester := &testing.T{}
+	assert.True(t, AssertSameSession(tester, login, cart[0]))
+	assert.False(t, tester.Failed())
+
+	assert.False(t, AssertSameSession(tester, login, cart[1]))
+	assert.True(t, tester.Failed())
+}
+
+func TestAssertCookieSent(t *testing.T) {
+	server, requestRecorder := NewServer(http.MethodGet, "/cart")
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/cart", http.NoBody)
+	request.AddCookie(&http.Cookie{Name: "token", Value: "123"})
+	_, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+
+	tester := &testing.T{}
+	assert.True(t, requestRecorder.AssertCookieSent(tester, "token", "123"))
+	assert.False(t, tester.Failed())
+
+	assert.False(t, requestRecorder.AssertCookieSent(tester, "theme", "dark"))
+	assert.True(t, tester.Failed())
+}
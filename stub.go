@@ -0,0 +1,96 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StubDefinition is the declarative form of a route and its response, using
+// the vocabulary of the option functions. Timeout is a duration string such
+// as "250ms".
+type StubDefinition struct {
+	Method        string      `json:"method"`
+	Path          string      `json:"path"`
+	Host          string      `json:"host,omitempty"`
+	StatusCode    int         `json:"statusCode,omitempty"`
+	Header        http.Header `json:"header,omitempty"`
+	JSONBody      interface{} `json:"jsonBody,omitempty"`
+	StringBody    *string     `json:"stringBody,omitempty"`
+	Timeout       string      `json:"timeout,omitempty"`
+	CorruptedBody bool        `json:"corruptedBody,omitempty"`
+}
+
+func (d StubDefinition) Route() Route {
+	return Route{HttpMethod: strings.ToUpper(d.Method), Path: d.Path, Host: d.Host}
+}
+
+func (d StubDefinition) ResponseRuleOptions() ([]ResponseRuleOption, error) {
+	if d.Method == "" {
+		return nil, fieldError{field: "method", err: errors.New("method is required")}
+	}
+	if !strings.HasPrefix(d.Path, "/") {
+		return nil, fieldError{field: "path", err: fmt.Errorf("path %q must start with /", d.Path)}
+	}
+	if d.JSONBody != nil && d.StringBody != nil {
+		return nil, fieldError{field: "stringBody", err: errors.New("jsonBody and stringBody are mutually exclusive")}
+	}
+
+	var options []ResponseRuleOption
+	if d.StatusCode != 0 {
+		if d.StatusCode < 100 || d.StatusCode > 999 {
+			return nil, fieldError{field: "statusCode", err: fmt.Errorf("invalid statusCode %d", d.StatusCode)}
+		}
+		options = append(options, StatusCode(d.StatusCode))
+	}
+	if len(d.Header) != 0 {
+		options = append(options, Header(d.Header))
+	}
+	if d.JSONBody != nil {
+		options = append(options, JSONBody(d.JSONBody))
+	}
+	if d.StringBody != nil {
+		options = append(options, StringBody(*d.StringBody))
+	}
+	if d.Timeout != "" {
+		timeout, err := time.ParseDuration(d.Timeout)
+		if err != nil {
+			return nil, fieldError{field: "timeout", err: fmt.Errorf("invalid timeout: %v", err)}
+		}
+		options = append(options, Timeout(timeout))
+	}
+	if d.CorruptedBody {
+		options = append(options, CorruptedBody())
+	}
+
+	return options, nil
+}
+
+// fieldError names the StubDefinition field an error is about so loaders can
+// point at its line.
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e fieldError) Error() string {
+	return e.err.Error()
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type callExpectation struct {
+	min int
+	// max is negative when unbounded.
+	max int
+}
+
+// New starts a Server that is closed and verified when t finishes. Routes
+// called a different number of times than ExpectTimes or ExpectCalled ask
+// for, and requests matching no route, fail the test. A failed test gets the
+// request journal in its log. A listener that can't be opened fails the test
+// right away.
+func New(t testing.TB, routeResponseOptions map[Route][]ResponseRuleOption, serverOptions ...ServerOption) *Server {
+	t.Helper()
+
+	server, err := StartManagedServer(routeResponseOptions, serverOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		server.Verify(t)
+		if t.Failed() {
+			server.LogJournal(t)
+		}
+	})
+
+	return server
+}
+
+// Verify fails t for every unmet call expectation and every request that
+// matched no route.
+func (s *Server) Verify(t testing.TB) {
+	t.Helper()
+
+	s.mu.RLock()
+	routes := make([]Route, 0, len(s.rules))
+	for route := range s.rules {
+		routes = append(routes, route)
+	}
+	sortRoutes(routes)
+	for _, route := range routes {
+		expectation := s.rules[route].expectation
+		if expectation == nil {
+			continue
+		}
+		recorder := s.requestRecorder[route]
+		recorder.mu.Lock()
+		calls := len(recorder.Requests)
+		recorder.mu.Unlock()
+
+		if !expectation.isMet(calls) {
+			t.Errorf("aduket: %s expected %s, called %d times", route, expectation, calls)
+		}
+	}
+	s.mu.RUnlock()
+
+	s.unmatched.mu.Lock()
+	unmatched := s.unmatched.Requests
+	s.unmatched.mu.Unlock()
+	for _, request := range unmatched {
+		t.Errorf("aduket: unexpected request %s %s", request.Method, request.Path)
+	}
+}
+
+// LogJournal writes every request the server received to the test log.
+func (s *Server) LogJournal(t testing.TB) {
+	t.Helper()
+
+	journal := s.Journal()
+	t.Logf("aduket: request journal of %s (%d requests)", s.URL(), len(journal))
+	for i, request := range journal {
+		t.Logf("#%d %s %s %s -> %d", i+1, request.ReceivedAt.Format("15:04:05.000"), request.Method, request.Path, request.StatusCode)
+		if len(request.QueryParams) != 0 {
+			t.Logf("    query: %s", request.QueryParams.Encode())
+		}
+		for _, name := range sortedHeaderNames(request.Header) {
+			t.Logf("    %s: %s", name, strings.Join(request.Header[name], ", "))
+		}
+		if len(request.Body) != 0 {
+			t.Logf("    body: %s", request.Body)
+		}
+	}
+}
+
+func (e callExpectation) isMet(calls int) bool {
+	return calls >= e.min && (e.max < 0 || calls <= e.max)
+}
+
+func (e callExpectation) String() string {
+	calls := "calls"
+	if e.min == 1 {
+		calls = "call"
+	}
+	if e.max < 0 {
+		return fmt.Sprintf("at least %d %s", e.min, calls)
+	}
+	return fmt.Sprintf("exactly %d %s", e.min, calls)
+}
+
+func sortRoutes(routes []Route) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Host != routes[j].Host {
+			return routes[i].Host < routes[j].Host
+		}
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].HttpMethod < routes[j].HttpMethod
+	})
+}
+
+func sortedHeaderNames(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,163 @@
+package aduket
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTB struct {
+	testing.TB
+	errors   []string
+	logs     []string
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Fatal(args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprint(args...))
+	runtime.Goexit()
+}
+
+func (f *fakeTB) Logf(format string, args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Cleanup(cleanup func()) {
+	f.cleanups = append(f.cleanups, cleanup)
+}
+
+func (f *fakeTB) Failed() bool {
+	return len(f.errors) != 0
+}
+
+func (f *fakeTB) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func TestNewClosesServerOnCleanup(t *testing.T) {
+	tb := &fakeTB{}
+	server := New(tb, map[Route][]ResponseRuleOption{{HttpMethod: http.MethodGet, Path: "/user"}: {}})
+
+	_, err := http.Get(server.URL() + "/user")
+	assert.Nil(t, err)
+
+	tb.finish()
+
+	assert.Empty(t, tb.errors)
+	assert.Empty(t, tb.logs)
+	_, err = http.Get(server.URL() + "/user")
+	assert.NotNil(t, err)
+}
+
+func TestNewFailsWhenListenFails(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	tb := &fakeTB{}
+	returned := false
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		New(tb, map[Route][]ResponseRuleOption{}, ListenAddress(listener.Addr().String()))
+		returned = true
+	}()
+	<-done
+
+	assert.False(t, returned)
+	assert.Len(t, tb.errors, 1)
+	assert.Contains(t, tb.errors[0], "aduket: failed to listen on tcp")
+}
+
+func TestNewVerifiesExpectations(t *testing.T) {
+	tb := &fakeTB{}
+	server := New(tb, map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/user"}:  {ExpectTimes(2)},
+		{HttpMethod: http.MethodGet, Path: "/book"}:  {ExpectCalled()},
+		{HttpMethod: http.MethodPost, Path: "/user"}: {ExpectCalled()},
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := http.Get(server.URL() + "/user")
+		assert.Nil(t, err)
+	}
+	_, err := http.Post(server.URL()+"/user", "text/plain", strings.NewReader("kalt"))
+	assert.Nil(t, err)
+
+	tb.finish()
+
+	assert.Equal(t, []string{
+		"aduket: GET /book expected at least 1 call, called 0 times",
+		"aduket: GET /user expected exactly 2 calls, called 3 times",
+	}, tb.errors)
+}
+
+func TestNewReportsUnexpectedRequests(t *testing.T) {
+	tb := &fakeTB{}
+	server := New(tb, map[Route][]ResponseRuleOption{{HttpMethod: http.MethodGet, Path: "/user"}: {}})
+
+	response, err := http.Get(server.URL() + "/book?id=1")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	response, err = http.Post(server.URL()+"/user", "text/plain", strings.NewReader("kalt"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+
+	assert.Len(t, server.Unmatched().Requests, 2)
+
+	tb.finish()
+
+	assert.Equal(t, []string{
+		"aduket: unexpected request GET /book",
+		"aduket: unexpected request POST /user",
+	}, tb.errors)
+}
+
+func TestNewLogsJournalOnFailure(t *testing.T) {
+	tb := &fakeTB{}
+	server := New(tb, map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodPost, Path: "/user"}: {StatusCode(http.StatusCreated), ExpectTimes(2)},
+	})
+
+	_, err := http.Post(server.URL()+"/user", "application/json", strings.NewReader(`{"name":"kalt"}`))
+	assert.Nil(t, err)
+	_, err = http.Get(server.URL() + "/missing?page=2")
+	assert.Nil(t, err)
+
+	tb.finish()
+
+	journal := strings.Join(tb.logs, "\n")
+	assert.Contains(t, journal, "(2 requests)")
+	assert.Regexp(t, `#1 \S+ POST /user -> 201`, journal)
+	assert.Contains(t, journal, `body: {"name":"kalt"}`)
+	assert.Regexp(t, `#2 \S+ GET /missing -> 404`, journal)
+	assert.Contains(t, journal, "query: page=2")
+}
+
+func TestServerResetClearsUnmatched(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{})
+	defer server.Close()
+
+	_, err := http.Get(server.URL() + "/missing")
+	assert.Nil(t, err)
+	assert.Len(t, server.Journal(), 1)
+
+	server.Reset()
+
+	server.Unmatched().AssertNoRequest(t)
+	assert.Empty(t, server.Journal())
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+type CertificateFault int
+
+const (
+	NoCertificateFault CertificateFault = iota
+	// ExpiredCertificate serves a certificate whose validity ended yesterday.
+	ExpiredCertificate
+	// WrongSANCertificate serves a certificate valid only for wrong.invalid.
+	WrongSANCertificate
+	// UntrustedCertificate serves a certificate signed by a throwaway CA
+	// instead of TLSOptions.CA.
+	UntrustedCertificate
+)
+
+// TLSOptions configures the TLS variants of the server constructors. A nil
+// CA gets a fresh one; use CertificateAuthority.Client or the server's own
+// Client to talk to the server.
+type TLSOptions struct {
+	CA *CertificateAuthority
+	// ClientAuth enables mutual TLS, e.g. tls.RequireAndVerifyClientCert.
+	ClientAuth tls.ClientAuthType
+	// ClientCA verifies client certificates. It defaults to CA.
+	ClientCA   *CertificateAuthority
+	Fault      CertificateFault
+	MinVersion uint16
+	MaxVersion uint16
+	// EnableHTTP2 offers h2 alongside http/1.1 during ALPN.
+	EnableHTTP2 bool
+}
+
+type CertificateAuthority struct {
+	Certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func NewCertificateAuthority() *CertificateAuthority {
+	key := newECDSAKey()
+	template := &x509.Certificate{
+		SerialNumber:          newSerialNumber(),
+		Subject:               pkix.Name{Organization: []string{"aduket"}, CommonName: "aduket test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+
+	return &CertificateAuthority{Certificate: certificate, key: key}
+}
+
+func (ca *CertificateAuthority) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate)
+	return pool
+}
+
+// Client returns an *http.Client trusting only ca, presenting clientCertificates
+// for mutual TLS.
+func (ca *CertificateAuthority) Client(clientCertificates ...tls.Certificate) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      ca.CertPool(),
+				Certificates: clientCertificates,
+			},
+			ForceAttemptHTTP2: true,
+		},
+	}
+}
+
+func (ca *CertificateAuthority) IssueServerCertificate(hosts ...string) tls.Certificate {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{Organization: []string{"aduket"}, CommonName: "aduket server"},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	return ca.issue(template)
+}
+
+func (ca *CertificateAuthority) IssueClientCertificate(commonName string) tls.Certificate {
+	return ca.issue(&x509.Certificate{
+		Subject:     pkix.Name{Organization: []string{"aduket"}, CommonName: commonName},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+func (ca *CertificateAuthority) issue(template *x509.Certificate) tls.Certificate {
+	key := newECDSAKey()
+	template.SerialNumber = newSerialNumber()
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, &key.PublicKey, ca.key)
+	if err != nil {
+		panic(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func NewTLSServer(options TLSOptions, httpMethod, path string, responseRuleOptions ...ResponseRuleOption) (*httptest.Server, *RequestRecorder) {
+	return NewServerWithOptions(httpMethod, path, responseRuleOptions, ServeTLS(options))
+}
+
+func NewMultiRouteTLSServer(options TLSOptions, routeResponseOptions map[Route][]ResponseRuleOption) (*httptest.Server, map[Route]*RequestRecorder) {
+	return NewMultiRouteServer(routeResponseOptions, ServeTLS(options))
+}
+
+func (o TLSOptions) config() *tls.Config {
+	ca := o.CA
+	if ca == nil {
+		ca = NewCertificateAuthority()
+	}
+
+	var certificate tls.Certificate
+	switch o.Fault {
+	case ExpiredCertificate:
+		certificate = ca.issue(&x509.Certificate{
+			Subject:     pkix.Name{Organization: []string{"aduket"}, CommonName: "aduket expired server"},
+			NotBefore:   time.Now().Add(-48 * time.Hour),
+			NotAfter:    time.Now().Add(-24 * time.Hour),
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+			DNSNames:    []string{"localhost"},
+		})
+	case WrongSANCertificate:
+		certificate = ca.IssueServerCertificate("wrong.invalid")
+	case UntrustedCertificate:
+		certificate = NewCertificateAuthority().IssueServerCertificate("127.0.0.1", "::1", "localhost")
+	default:
+		certificate = ca.IssueServerCertificate("127.0.0.1", "::1", "localhost")
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   o.ClientAuth,
+		MinVersion:   o.MinVersion,
+		MaxVersion:   o.MaxVersion,
+	}
+	if o.EnableHTTP2 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	if o.ClientAuth != tls.NoClientCert {
+		clientCA := o.ClientCA
+		if clientCA == nil {
+			clientCA = ca
+		}
+		config.ClientCAs = clientCA.CertPool()
+	}
+
+	return config
+}
+
+func newECDSAKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func newSerialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(err)
+	}
+	return serial
+}
@@ -0,0 +1,101 @@
+package aduket
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSServer(t *testing.T) {
+	ca := NewCertificateAuthority()
+	server, requestRecorder := NewTLSServer(TLSOptions{CA: ca}, http.MethodGet, "/user", StringBody("Hello"))
+	defer server.Close()
+
+	for _, client := range []*http.Client{ca.Client(), server.Client()} {
+		response, err := client.Get(server.URL + "/user")
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	assert.True(t, strings.HasPrefix(server.URL, "https://"))
+	requestRecorder.AssertTLSVersion(t, tls.VersionTLS13)
+
+	_, err := http.Get(server.URL + "/user")
+	assert.NotNil(t, err)
+}
+
+func TestMutualTLSServer(t *testing.T) {
+	ca := NewCertificateAuthority()
+	server, requestRecorder := NewMultiRouteTLSServer(
+		TLSOptions{CA: ca, ClientAuth: tls.RequireAndVerifyClientCert},
+		map[Route][]ResponseRuleOption{{HttpMethod: http.MethodGet, Path: "/user"}: {}},
+	)
+	defer server.Close()
+	route := Route{HttpMethod: http.MethodGet, Path: "/user"}
+
+	_, err := ca.Client().Get(server.URL + "/user")
+	assert.NotNil(t, err)
+	requestRecorder[route].AssertNoRequest(t)
+
+	_, err = ca.Client(NewCertificateAuthority().IssueClientCertificate("mallory")).Get(server.URL + "/user")
+	assert.NotNil(t, err)
+
+	response, err := ca.Client(ca.IssueClientCertificate("cart-service")).Get(server.URL + "/user")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	tester := &testing.T{}
+	assert.True(t, requestRecorder[route].AssertClientCertificate(tester, "cart-service"))
+	assert.False(t, tester.Failed())
+
+	assert.False(t, requestRecorder[route].AssertClientCertificate(tester, "mallory"))
+	assert.True(t, tester.Failed())
+}
+
+func TestTLSServerFaults(t *testing.T) {
+	tests := []struct {
+		fault   CertificateFault
+		isFault func(error) bool
+	}{
+		{ExpiredCertificate, func(err error) bool {
+			var invalid x509.CertificateInvalidError
+			return errors.As(err, &invalid) && invalid.Reason == x509.Expired
+		}},
+		{WrongSANCertificate, func(err error) bool {
+			var hostname x509.HostnameError
+			return errors.As(err, &hostname)
+		}},
+		{UntrustedCertificate, func(err error) bool {
+			var unknownAuthority x509.UnknownAuthorityError
+			return errors.As(err, &unknownAuthority)
+		}},
+	}
+
+	for _, test := range tests {
+		ca := NewCertificateAuthority()
+		server, requestRecorder := NewTLSServer(TLSOptions{CA: ca, Fault: test.fault}, http.MethodGet, "/user")
+
+		_, err := ca.Client().Get(server.URL + "/user")
+		assert.True(t, test.isFault(err), "fault %d: %v", test.fault, err)
+		requestRecorder.AssertNoRequest(t)
+
+		server.Close()
+	}
+}
+
+func TestTLSServerVersion(t *testing.T) {
+	ca := NewCertificateAuthority()
+	server, _ := NewTLSServer(TLSOptions{CA: ca, MaxVersion: tls.VersionTLS12}, http.MethodGet, "/user")
+	defer server.Close()
+
+	client := ca.Client()
+	client.Transport.(*http.Transport).TLSClientConfig.MinVersion = tls.VersionTLS13
+
+	_, err := client.Get(server.URL + "/user")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,702 @@
+// Copyright 2020 StreetByters Community
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aduket
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	WireMockStartedState = "Started"
+
+	wireMockDefaultPriority = 5
+)
+
+// WireMockMappings is the {"mappings": [...]} document WireMock imports and
+// exports.
+type WireMockMappings struct {
+	Mappings []WireMockMapping `json:"mappings"`
+}
+
+type WireMockMapping struct {
+	ID       string           `json:"id,omitempty"`
+	Name     string           `json:"name,omitempty"`
+	Priority int              `json:"priority,omitempty"`
+	Request  WireMockRequest  `json:"request"`
+	Response WireMockResponse `json:"response"`
+
+	ScenarioName          string `json:"scenarioName,omitempty"`
+	RequiredScenarioState string `json:"requiredScenarioState,omitempty"`
+	NewScenarioState      string `json:"newScenarioState,omitempty"`
+
+	// filesDir is the __files directory bodyFileName is read from.
+	filesDir string
+}
+
+// WireMockRequest matches requests. At most one of URL, URLPath, URLPattern
+// and URLPathPattern is set; patterns are regular expressions that must
+// match the whole path, or path and query.
+type WireMockRequest struct {
+	Method          string                     `json:"method,omitempty"`
+	Host            *WireMockPattern           `json:"host,omitempty"`
+	URL             string                     `json:"url,omitempty"`
+	URLPath         string                     `json:"urlPath,omitempty"`
+	URLPattern      string                     `json:"urlPattern,omitempty"`
+	URLPathPattern  string                     `json:"urlPathPattern,omitempty"`
+	QueryParameters map[string]WireMockPattern `json:"queryParameters,omitempty"`
+	Headers         map[string]WireMockPattern `json:"headers,omitempty"`
+	BodyPatterns    []WireMockPattern          `json:"bodyPatterns,omitempty"`
+}
+
+// WireMockPattern is a WireMock value matcher. Matchers aduket doesn't
+// implement, such as matchesJsonPath, are rejected when loading.
+type WireMockPattern struct {
+	EqualTo         *string `json:"equalTo,omitempty"`
+	Contains        string  `json:"contains,omitempty"`
+	Matches         string  `json:"matches,omitempty"`
+	DoesNotMatch    string  `json:"doesNotMatch,omitempty"`
+	Absent          bool    `json:"absent,omitempty"`
+	CaseInsensitive bool    `json:"caseInsensitive,omitempty"`
+
+	// EqualToJSON holds the expected document, inline or as a JSON string.
+	EqualToJSON         json.RawMessage `json:"equalToJson,omitempty"`
+	IgnoreArrayOrder    bool            `json:"ignoreArrayOrder,omitempty"`
+	IgnoreExtraElements bool            `json:"ignoreExtraElements,omitempty"`
+}
+
+type WireMockResponse struct {
+	Status                 int             `json:"status,omitempty"`
+	Headers                WireMockHeaders `json:"headers,omitempty"`
+	Body                   *string         `json:"body,omitempty"`
+	JSONBody               json.RawMessage `json:"jsonBody,omitempty"`
+	Base64Body             string          `json:"base64Body,omitempty"`
+	BodyFileName           string          `json:"bodyFileName,omitempty"`
+	FixedDelayMilliseconds int             `json:"fixedDelayMilliseconds,omitempty"`
+}
+
+// WireMockHeaders reads header values written as a string or as a list.
+type WireMockHeaders http.Header
+
+func (h *WireMockHeaders) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	for name, value := range raw {
+		var values []string
+		if err := json.Unmarshal(value, &values); err == nil {
+			header[http.CanonicalHeaderKey(name)] = values
+			continue
+		}
+		var single string
+		if err := json.Unmarshal(value, &single); err != nil {
+			return fmt.Errorf("header %s must be a string or a list of strings", name)
+		}
+		header.Set(name, single)
+	}
+	*h = WireMockHeaders(header)
+	return nil
+}
+
+func (h WireMockHeaders) MarshalJSON() ([]byte, error) {
+	headers := make(map[string]interface{}, len(h))
+	for name, values := range h {
+		if len(values) == 1 {
+			headers[name] = values[0]
+		} else {
+			headers[name] = values
+		}
+	}
+	return json.Marshal(headers)
+}
+
+func (p *WireMockPattern) UnmarshalJSON(data []byte) error {
+	type pattern WireMockPattern
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode((*pattern)(p)); err != nil {
+		return fmt.Errorf("unsupported matcher: %v", err)
+	}
+	return nil
+}
+
+// LoadWireMockMappings reads a mapping file, either a single mapping or a
+// {"mappings": [...]} document, or every .json file of a directory. Like
+// WireMock, a directory holding a mappings directory is read as a root, and
+// bodyFileName is resolved against the __files directory next to mappings.
+func LoadWireMockMappings(path string) (*WireMockMappings, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return loadWireMockMappingFile(path)
+	}
+
+	mappingsDir := path
+	if info, err := os.Stat(filepath.Join(path, "mappings")); err == nil && info.IsDir() {
+		mappingsDir = filepath.Join(path, "mappings")
+	}
+	files, err := filepath.Glob(filepath.Join(mappingsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	mappings := &WireMockMappings{}
+	for _, file := range files {
+		fileMappings, err := loadWireMockMappingFile(file)
+		if err != nil {
+			return nil, err
+		}
+		mappings.Mappings = append(mappings.Mappings, fileMappings.Mappings...)
+	}
+	return mappings, nil
+}
+
+func loadWireMockMappingFile(path string) (*WireMockMappings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("aduket: invalid WireMock mapping %s: %v", path, err)
+	}
+
+	mappings := &WireMockMappings{}
+	if document.Mappings != nil {
+		err = json.Unmarshal(document.Mappings, &mappings.Mappings)
+	} else {
+		mappings.Mappings = make([]WireMockMapping, 1)
+		err = json.Unmarshal(data, &mappings.Mappings[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("aduket: invalid WireMock mapping %s: %v", path, err)
+	}
+
+	filesDir := filepath.Join(filepath.Dir(filepath.Dir(path)), "__files")
+	for i := range mappings.Mappings {
+		mappings.Mappings[i].filesDir = filesDir
+	}
+	return mappings, nil
+}
+
+func (m *WireMockMappings) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// NewServerFromWireMock serves the mappings found at path, see
+// LoadWireMockMappings. Mappings are tried by priority, the last loaded first
+// among equals, and scenarios start in WireMockStartedState. Recorders are
+// keyed by mapping name, id or "METHOD url", in that order of preference;
+// mappings sharing a key share a recorder.
+func NewServerFromWireMock(path string, serverOptions ...ServerOption) (*httptest.Server, map[string]*RequestRecorder) {
+	mappings, err := LoadWireMockMappings(path)
+	if err != nil {
+		panic(err)
+	}
+
+	stubs, err := newWireMockStubs(mappings.Mappings)
+	if err != nil {
+		panic(err)
+	}
+
+	e := createEcho()
+	e.Any("/*", stubs.serve)
+	return startServer(e, serverOptions...), stubs.requestRecorder
+}
+
+type wireMockStubs struct {
+	stubs           []*wireMockStub
+	requestRecorder map[string]*RequestRecorder
+
+	mu     sync.Mutex
+	states map[string]string
+}
+
+type wireMockStub struct {
+	mapping    WireMockMapping
+	urlPattern *regexp.Regexp
+	host       *wireMockMatcher
+	query      map[string]*wireMockMatcher
+	header     map[string]*wireMockMatcher
+	body       []*wireMockMatcher
+	handler    echo.HandlerFunc
+	priority   int
+}
+
+func newWireMockStubs(mappings []WireMockMapping) (*wireMockStubs, error) {
+	s := &wireMockStubs{
+		requestRecorder: make(map[string]*RequestRecorder),
+		states:          make(map[string]string),
+	}
+
+	for i := len(mappings) - 1; i >= 0; i-- {
+		mapping := mappings[i]
+		stub, err := newWireMockStub(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("aduket: WireMock mapping %s: %v", mapping.key(), err)
+		}
+
+		options, err := mapping.Response.responseRuleOptions(mapping.filesDir)
+		if err != nil {
+			return nil, fmt.Errorf("aduket: WireMock mapping %s: %v", mapping.key(), err)
+		}
+		recorder, ok := s.requestRecorder[mapping.key()]
+		if !ok {
+			recorder = NewRequestRecorder()
+			s.requestRecorder[mapping.key()] = recorder
+		}
+		stub.handler = spyHandler(recorder, createResponseRule(options))
+
+		if mapping.ScenarioName != "" {
+			s.states[mapping.ScenarioName] = WireMockStartedState
+		}
+		s.stubs = append(s.stubs, stub)
+	}
+
+	sort.SliceStable(s.stubs, func(i, j int) bool {
+		return s.stubs[i].priority < s.stubs[j].priority
+	})
+	return s, nil
+}
+
+func (m WireMockMapping) key() string {
+	switch {
+	case m.Name != "":
+		return m.Name
+	case m.ID != "":
+		return m.ID
+	}
+
+	method := m.Request.Method
+	if method == "" {
+		method = "ANY"
+	}
+	for _, url := range []string{m.Request.URL, m.Request.URLPath, m.Request.URLPattern, m.Request.URLPathPattern} {
+		if url != "" {
+			return method + " " + url
+		}
+	}
+	return method
+}
+
+func newWireMockStub(mapping WireMockMapping) (*wireMockStub, error) {
+	stub := &wireMockStub{
+		mapping:  mapping,
+		query:    make(map[string]*wireMockMatcher),
+		header:   make(map[string]*wireMockMatcher),
+		priority: mapping.Priority,
+	}
+	if stub.priority == 0 {
+		stub.priority = wireMockDefaultPriority
+	}
+
+	var err error
+	if pattern := firstNonEmpty(mapping.Request.URLPattern, mapping.Request.URLPathPattern); pattern != "" {
+		if stub.urlPattern, err = regexp.Compile("^(?:" + pattern + ")$"); err != nil {
+			return nil, err
+		}
+	}
+	if mapping.Request.Host != nil {
+		if stub.host, err = newWireMockMatcher(*mapping.Request.Host); err != nil {
+			return nil, fmt.Errorf("host: %v", err)
+		}
+	}
+	for name, pattern := range mapping.Request.QueryParameters {
+		if stub.query[name], err = newWireMockMatcher(pattern); err != nil {
+			return nil, fmt.Errorf("query parameter %s: %v", name, err)
+		}
+	}
+	for name, pattern := range mapping.Request.Headers {
+		if stub.header[name], err = newWireMockMatcher(pattern); err != nil {
+			return nil, fmt.Errorf("header %s: %v", name, err)
+		}
+	}
+	for i, pattern := range mapping.Request.BodyPatterns {
+		matcher, err := newWireMockMatcher(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bodyPatterns[%d]: %v", i, err)
+		}
+		stub.body = append(stub.body, matcher)
+	}
+	return stub, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (r WireMockResponse) responseRuleOptions(filesDir string) ([]ResponseRuleOption, error) {
+	statusCode := r.Status
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	options := []ResponseRuleOption{StatusCode(statusCode)}
+	if len(r.Headers) != 0 {
+		options = append(options, Header(http.Header(r.Headers)))
+	}
+	if r.FixedDelayMilliseconds > 0 {
+		options = append(options, Timeout(time.Duration(r.FixedDelayMilliseconds)*time.Millisecond))
+	}
+
+	switch {
+	case r.Body != nil:
+		options = append(options, ByteBody([]byte(*r.Body)))
+	case len(r.JSONBody) != 0:
+		var body bytes.Buffer
+		if err := json.Compact(&body, r.JSONBody); err != nil {
+			return nil, err
+		}
+		options = append(options, ByteBody(body.Bytes()))
+	case r.Base64Body != "":
+		body, err := base64.StdEncoding.DecodeString(r.Base64Body)
+		if err != nil {
+			return nil, fmt.Errorf("base64Body: %v", err)
+		}
+		options = append(options, ByteBody(body))
+	case r.BodyFileName != "":
+		body, err := ioutil.ReadFile(filepath.Join(filesDir, filepath.FromSlash(r.BodyFileName)))
+		if err != nil {
+			return nil, fmt.Errorf("bodyFileName: %v", err)
+		}
+		options = append(options, ByteBody(body))
+	}
+	return options, nil
+}
+
+func (s *wireMockStubs) serve(ctx echo.Context) error {
+	request := ctx.Request()
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return err
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	var matched *wireMockStub
+	for _, stub := range s.stubs {
+		if stub.matches(request, body, s.states) {
+			matched = stub
+			break
+		}
+	}
+	if matched != nil && matched.mapping.NewScenarioState != "" {
+		s.states[matched.mapping.ScenarioName] = matched.mapping.NewScenarioState
+	}
+	s.mu.Unlock()
+
+	if matched == nil {
+		return ctx.String(http.StatusNotFound, fmt.Sprintf("aduket: no WireMock mapping matches %s %s", request.Method, request.URL.RequestURI()))
+	}
+	return matched.handler(ctx)
+}
+
+func (s *wireMockStub) matches(request *http.Request, body []byte, states map[string]string) bool {
+	mapping := s.mapping
+	if mapping.RequiredScenarioState != "" && states[mapping.ScenarioName] != mapping.RequiredScenarioState {
+		return false
+	}
+	if method := mapping.Request.Method; method != "" && method != "ANY" && !strings.EqualFold(method, request.Method) {
+		return false
+	}
+	if s.host != nil && !s.host.matches(normalizeHost(request.Host)) {
+		return false
+	}
+
+	switch {
+	case mapping.Request.URL != "":
+		if request.URL.RequestURI() != mapping.Request.URL {
+			return false
+		}
+	case mapping.Request.URLPath != "":
+		if request.URL.Path != mapping.Request.URLPath {
+			return false
+		}
+	case mapping.Request.URLPattern != "":
+		if !s.urlPattern.MatchString(request.URL.RequestURI()) {
+			return false
+		}
+	case mapping.Request.URLPathPattern != "":
+		if !s.urlPattern.MatchString(request.URL.Path) {
+			return false
+		}
+	}
+
+	query := request.URL.Query()
+	for name, matcher := range s.query {
+		if !matcher.matchesAny(query[name]) {
+			return false
+		}
+	}
+	for name, matcher := range s.header {
+		if !matcher.matchesAny(request.Header.Values(name)) {
+			return false
+		}
+	}
+	for _, matcher := range s.body {
+		if !matcher.matches(string(body)) {
+			return false
+		}
+	}
+	return true
+}
+
+type wireMockMatcher struct {
+	pattern      WireMockPattern
+	match        *regexp.Regexp
+	doesNotMatch *regexp.Regexp
+	json         interface{}
+}
+
+func newWireMockMatcher(pattern WireMockPattern) (*wireMockMatcher, error) {
+	m := &wireMockMatcher{pattern: pattern}
+
+	var err error
+	if pattern.Matches != "" {
+		if m.match, err = regexp.Compile("^(?:" + pattern.Matches + ")$"); err != nil {
+			return nil, err
+		}
+	}
+	if pattern.DoesNotMatch != "" {
+		if m.doesNotMatch, err = regexp.Compile("^(?:" + pattern.DoesNotMatch + ")$"); err != nil {
+			return nil, err
+		}
+	}
+	if len(pattern.EqualToJSON) != 0 {
+		document := []byte(pattern.EqualToJSON)
+		var inline string
+		if json.Unmarshal(document, &inline) == nil {
+			document = []byte(inline)
+		}
+		if err := json.Unmarshal(document, &m.json); err != nil {
+			return nil, fmt.Errorf("equalToJson: %v", err)
+		}
+	}
+	return m, nil
+}
+
+func (m *wireMockMatcher) matchesAny(values []string) bool {
+	if m.pattern.Absent {
+		return len(values) == 0
+	}
+	for _, value := range values {
+		if m.matches(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *wireMockMatcher) matches(value string) bool {
+	pattern := m.pattern
+	if pattern.EqualTo != nil {
+		if pattern.CaseInsensitive && !strings.EqualFold(*pattern.EqualTo, value) {
+			return false
+		}
+		if !pattern.CaseInsensitive && *pattern.EqualTo != value {
+			return false
+		}
+	}
+	if pattern.Contains != "" && !strings.Contains(value, pattern.Contains) {
+		return false
+	}
+	if m.match != nil && !m.match.MatchString(value) {
+		return false
+	}
+	if m.doesNotMatch != nil && m.doesNotMatch.MatchString(value) {
+		return false
+	}
+	if m.json != nil {
+		var actual interface{}
+		if err := json.Unmarshal([]byte(value), &actual); err != nil {
+			return false
+		}
+		return wireMockJSONEqual(m.json, actual, pattern.IgnoreArrayOrder, pattern.IgnoreExtraElements)
+	}
+	return true
+}
+
+func wireMockJSONEqual(expected, actual interface{}, ignoreArrayOrder, ignoreExtraElements bool) bool {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		actual, ok := actual.(map[string]interface{})
+		if !ok || (!ignoreExtraElements && len(actual) != len(expected)) {
+			return false
+		}
+		for key, value := range expected {
+			actualValue, ok := actual[key]
+			if !ok || !wireMockJSONEqual(value, actualValue, ignoreArrayOrder, ignoreExtraElements) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actual, ok := actual.([]interface{})
+		if !ok || len(actual) < len(expected) || (!ignoreExtraElements && len(actual) != len(expected)) {
+			return false
+		}
+		if !ignoreArrayOrder {
+			for i := range expected {
+				if !wireMockJSONEqual(expected[i], actual[i], ignoreArrayOrder, ignoreExtraElements) {
+					return false
+				}
+			}
+			return true
+		}
+
+		used := make([]bool, len(actual))
+		for _, value := range expected {
+			found := false
+			for i, actualValue := range actual {
+				if !used[i] && wireMockJSONEqual(value, actualValue, ignoreArrayOrder, ignoreExtraElements) {
+					used[i], found = true, true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(expected, actual)
+	}
+}
+
+// NewWireMockMappings describes routes as WireMock mappings. Routes replaying
+// several HAR entries become scenarios named after the route. Bodies built
+// per request, such as those of NewServerFromOpenAPI, are not exported.
+func NewWireMockMappings(routeResponseOptions map[Route][]ResponseRuleOption) *WireMockMappings {
+	rules := make(map[Route]responseRule, len(routeResponseOptions))
+	for route, options := range routeResponseOptions {
+		rules[route] = createResponseRule(options)
+	}
+	return newWireMockMappings(rules)
+}
+
+func (s *Server) WireMockMappings() *WireMockMappings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return newWireMockMappings(s.rules)
+}
+
+func newWireMockMappings(rules map[Route]responseRule) *WireMockMappings {
+	routes := make([]Route, 0, len(rules))
+	for route := range rules {
+		routes = append(routes, route)
+	}
+	sortRoutes(routes)
+
+	mappings := &WireMockMappings{Mappings: []WireMockMapping{}}
+	for _, route := range routes {
+		request := WireMockRequest{Method: strings.ToUpper(route.HttpMethod)}
+		if route.Host != "" {
+			host := normalizeHost(route.Host)
+			request.Host = &WireMockPattern{EqualTo: &host}
+		}
+		if strings.ContainsAny(route.Path, ":*") {
+			request.URLPathPattern = routePathRegex(route.Path)
+		} else {
+			request.URLPath = route.Path
+		}
+
+		rule := rules[route]
+		if rule.sequence == nil || len(rule.sequence.rules) == 0 {
+			mappings.Mappings = append(mappings.Mappings, WireMockMapping{Request: request, Response: newWireMockResponse(rule)})
+			continue
+		}
+
+		scenario := route.String()
+		for i, sequenceRule := range rule.sequence.rules {
+			mapping := WireMockMapping{
+				Request:               request,
+				Response:              newWireMockResponse(sequenceRule),
+				ScenarioName:          scenario,
+				RequiredScenarioState: wireMockSequenceState(i),
+			}
+			if i < len(rule.sequence.rules)-1 {
+				mapping.NewScenarioState = wireMockSequenceState(i + 1)
+			}
+			mappings.Mappings = append(mappings.Mappings, mapping)
+		}
+	}
+	return mappings
+}
+
+func wireMockSequenceState(index int) string {
+	if index == 0 {
+		return WireMockStartedState
+	}
+	return fmt.Sprintf("response %d", index+1)
+}
+
+func newWireMockResponse(rule responseRule) WireMockResponse {
+	response := WireMockResponse{
+		Status:                 rule.statusCode,
+		FixedDelayMilliseconds: int(rule.timeout / time.Millisecond),
+	}
+	if len(rule.header) != 0 {
+		response.Headers = WireMockHeaders(rule.header.Clone())
+	}
+
+	body := []byte(rule.body)
+	switch {
+	case len(body) == 0:
+	case json.Valid(body) && (body[0] == '{' || body[0] == '['):
+		response.JSONBody = json.RawMessage(body)
+	case utf8.Valid(body):
+		text := string(body)
+		response.Body = &text
+	default:
+		response.Base64Body = base64.StdEncoding.EncodeToString(body)
+	}
+	return response
+}
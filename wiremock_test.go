@@ -0,0 +1,191 @@
+package aduket
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func wireMockDo(t *testing.T, method, url, body string, header http.Header) (int, http.Header, string) {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.Nil(t, err)
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+
+	responseBody, _ := ioutil.ReadAll(response.Body)
+	return response.StatusCode, response.Header, string(responseBody)
+}
+
+func TestNewServerFromWireMock(t *testing.T) {
+	server, requestRecorder := NewServerFromWireMock("testdata/wiremock")
+	defer server.Close()
+
+	statusCode, header, body := wireMockDo(t, http.MethodGet, server.URL+"/users/5", "", nil)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.JSONEq(t, `{"id": 1, "name": "kalt"}`, body)
+
+	_, _, body = wireMockDo(t, http.MethodGet, server.URL+"/users/0", "", nil)
+	assert.JSONEq(t, `{"id": 1, "name": "kalt"}`, body)
+	_, _, body = wireMockDo(t, http.MethodGet, server.URL+"/users/0", "", http.Header{"Authorization": {"Bearer token"}})
+	assert.Equal(t, `{"id":0,"name":"admin"}`, body)
+	assert.Len(t, requestRecorder["get user"].Requests, 2)
+	requestRecorder["get admin"].AssertHeaderContains(t, http.Header{"Authorization": {"Bearer token"}})
+
+	started := time.Now()
+	statusCode, header, _ = wireMockDo(t, http.MethodPost, server.URL+"/users?notify=true", `{"tags": ["b", "a"], "name": "kalt"}`, nil)
+	assert.Equal(t, http.StatusCreated, statusCode)
+	assert.Equal(t, "/users/2", header.Get("Location"))
+	assert.True(t, time.Since(started) >= 20*time.Millisecond)
+	requestRecorder["create user"].AssertQueryParamEqual(t, "notify", []string{"true"})
+
+	statusCode, _, body = wireMockDo(t, http.MethodPost, server.URL+"/users?notify=true", `{"name": "kalt", "tags": ["a"]}`, nil)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	assert.Equal(t, "aduket: no WireMock mapping matches POST /users?notify=true", body)
+
+	statusCode, _, body = wireMockDo(t, http.MethodDelete, server.URL+"/users?q=kalt", "", nil)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "kalt", body)
+	statusCode, _, _ = wireMockDo(t, http.MethodGet, server.URL+"/users?q=kalt&debug=1", "", nil)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	statusCode, _, _ = wireMockDo(t, http.MethodGet, server.URL+"/users?q=zz", "", nil)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+}
+
+func TestNewServerFromWireMockScenario(t *testing.T) {
+	server, requestRecorder := NewServerFromWireMock("testdata/wiremock/mappings/todos.json")
+	defer server.Close()
+
+	_, _, body := wireMockDo(t, http.MethodGet, server.URL+"/todos", "", nil)
+	assert.Equal(t, "[]", body)
+
+	statusCode, _, _ := wireMockDo(t, http.MethodPost, server.URL+"/todos", "eggs", nil)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	statusCode, _, _ = wireMockDo(t, http.MethodPost, server.URL+"/todos", "buy milk", nil)
+	assert.Equal(t, http.StatusCreated, statusCode)
+	statusCode, _, _ = wireMockDo(t, http.MethodPost, server.URL+"/todos", "buy milk", nil)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+
+	_, _, body = wireMockDo(t, http.MethodGet, server.URL+"/todos", "", nil)
+	assert.Equal(t, `["buy milk"]`, body)
+
+	assert.Len(t, requestRecorder["GET /todos"].Requests, 2)
+	requestRecorder["POST /todos"].AssertStringBodyEqual(t, "buy milk")
+}
+
+func TestLoadWireMockMappings(t *testing.T) {
+	dir := t.TempDir()
+	mappingPath := filepath.Join(dir, "health.json")
+	assert.Nil(t, ioutil.WriteFile(mappingPath, []byte(`{
+  "id": "8c5db8b0",
+  "request": {"method": "GET", "url": "/health"},
+  "response": {"status": 204},
+  "persistent": true
+}`), 0644))
+
+	mappings, err := LoadWireMockMappings(mappingPath)
+	assert.Nil(t, err)
+	assert.Len(t, mappings.Mappings, 1)
+	assert.Equal(t, "8c5db8b0", mappings.Mappings[0].ID)
+	assert.Equal(t, 204, mappings.Mappings[0].Response.Status)
+
+	mappings, err = LoadWireMockMappings("testdata/wiremock")
+	assert.Nil(t, err)
+	assert.Len(t, mappings.Mappings, 7)
+
+	unsupportedPath := filepath.Join(dir, "unsupported.json")
+	assert.Nil(t, ioutil.WriteFile(unsupportedPath, []byte(`{
+  "request": {"method": "POST", "bodyPatterns": [{"matchesJsonPath": "$.name"}]},
+  "response": {"status": 200}
+}`), 0644))
+	_, err = LoadWireMockMappings(unsupportedPath)
+	assert.Contains(t, err.Error(), `unsupported matcher: json: unknown field "matchesJsonPath"`)
+
+	invalidPath := filepath.Join(dir, "invalid.json")
+	assert.Nil(t, ioutil.WriteFile(invalidPath, []byte(`{
+  "name": "broken",
+  "request": {"urlPathPattern": "/users/("},
+  "response": {"status": 200}
+}`), 0644))
+	assert.PanicsWithError(t, "aduket: WireMock mapping broken: error parsing regexp: missing closing ): `^(?:/users/()$`", func() {
+		NewServerFromWireMock(invalidPath)
+	})
+}
+
+func TestNewWireMockMappings(t *testing.T) {
+	mappings := NewWireMockMappings(map[Route][]ResponseRuleOption{
+		{HttpMethod: http.MethodGet, Path: "/users/:id"}: {
+			JSONBody(User{ID: 1, Name: "kalt"}),
+			Header(http.Header{"Content-Type": []string{"application/json"}}),
+			Timeout(10 * time.Millisecond),
+		},
+		{HttpMethod: http.MethodGet, Path: "/avatar"}: {ByteBody([]byte{0xff, 0xd8})},
+		{HttpMethod: http.MethodPost, Path: "/jobs"}: {
+			sequenceOf(
+				[]ResponseRuleOption{StatusCode(http.StatusAccepted), StringBody("queued")},
+				[]ResponseRuleOption{StatusCode(http.StatusConflict), StringBody("busy")},
+			),
+		},
+	})
+	assert.Len(t, mappings.Mappings, 4)
+
+	avatar := mappings.Mappings[0]
+	assert.Equal(t, WireMockRequest{Method: http.MethodGet, URLPath: "/avatar"}, avatar.Request)
+	assert.Equal(t, "/9g=", avatar.Response.Base64Body)
+
+	queued, busy := mappings.Mappings[1], mappings.Mappings[2]
+	assert.Equal(t, "POST /jobs", queued.ScenarioName)
+	assert.Equal(t, WireMockStartedState, queued.RequiredScenarioState)
+	assert.Equal(t, "response 2", queued.NewScenarioState)
+	assert.Equal(t, "queued", *queued.Response.Body)
+	assert.Equal(t, "response 2", busy.RequiredScenarioState)
+	assert.Empty(t, busy.NewScenarioState)
+
+	user := mappings.Mappings[3]
+	assert.Equal(t, "/users/[^/]+", user.Request.URLPathPattern)
+	assert.Equal(t, 10, user.Response.FixedDelayMilliseconds)
+	assert.JSONEq(t, `{"id": 1, "name": "kalt"}`, string(user.Response.JSONBody))
+
+	data, err := json.Marshal(user.Response)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"headers":{"Content-Type":"application/json"}`)
+
+	mappingPath := filepath.Join(t.TempDir(), "mappings.json")
+	assert.Nil(t, mappings.Save(mappingPath))
+
+	server, _ := NewServerFromWireMock(mappingPath)
+	defer server.Close()
+
+	_, header, body := wireMockDo(t, http.MethodGet, server.URL+"/users/7", "", nil)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, `{"id":1,"name":"kalt"}`, body)
+	_, _, body = wireMockDo(t, http.MethodGet, server.URL+"/avatar", "", nil)
+	assert.Equal(t, "\xff\xd8", body)
+	for _, expected := range []string{"queued", "busy", "busy"} {
+		_, _, body = wireMockDo(t, http.MethodPost, server.URL+"/jobs", "", nil)
+		assert.Equal(t, expected, body)
+	}
+}
+
+func TestServerWireMockMappings(t *testing.T) {
+	server := NewManagedServer(map[Route][]ResponseRuleOption{{HttpMethod: http.MethodGet, Path: "/health"}: {StatusCode(http.StatusNoContent)}})
+	defer server.Close()
+	_, err := server.AddRoute(Route{HttpMethod: http.MethodGet, Path: "/ready"}, StringBody("ok"))
+	assert.Nil(t, err)
+
+	mappings := server.WireMockMappings()
+	assert.Len(t, mappings.Mappings, 2)
+	assert.Equal(t, http.StatusNoContent, mappings.Mappings[0].Response.Status)
+	assert.Equal(t, "ok", *mappings.Mappings[1].Response.Body)
+}